@@ -1,15 +1,38 @@
 package cache
 
 import (
+	"context"
+	"encoding/json"
+	"log/slog"
 	"sync"
 	"time"
 )
 
-// MetricsCache is a thread-safe cache for storing metrics data with TTL
+// Cache is a store for API data with a TTL, shared by the collector's scrapes.
+// MetricsCache keeps entries in memory, RedisCache in Redis so that replicas
+// of the exporter can share them.
+type Cache interface {
+	// Get returns the data stored under key, or false if there is none or it
+	// has expired
+	Get(key string) (interface{}, bool)
+	// Set stores data under key with the configured TTL
+	Set(key string, data interface{})
+	// Clear removes all entries
+	Clear()
+	// TTL returns the TTL applied to data stored from now on
+	TTL() time.Duration
+	// SetTTL changes the TTL applied to data stored from now on
+	SetTTL(ttl time.Duration)
+	// Backend returns the name of the storage backend, e.g. "memory"
+	Backend() string
+}
+
+// MetricsCache is a thread-safe cache for storing metrics data with TTL. It
+// holds independent entries per key, e.g. the listings of different accounts
+// or endpoints, each expiring on its own.
 type MetricsCache struct {
 	mu              sync.RWMutex
-	data            interface{}
-	expiration      time.Time
+	entries         map[string]cacheEntry
 	ttl             time.Duration
 	maxSize         int64
 	currentSize     int64
@@ -17,9 +40,17 @@ type MetricsCache struct {
 	lastCleanup     time.Time
 }
 
+// cacheEntry is a cached value with its expiration time and estimated size
+type cacheEntry struct {
+	data       interface{}
+	expiration time.Time
+	size       int64
+}
+
 // NewMetricsCache creates a new cache instance with the specified configuration
 func NewMetricsCache(ttl time.Duration, maxSize int64, cleanupInterval time.Duration) *MetricsCache {
 	return &MetricsCache{
+		entries:         make(map[string]cacheEntry),
 		ttl:             ttl,
 		maxSize:         maxSize,
 		cleanupInterval: cleanupInterval,
@@ -27,35 +58,89 @@ func NewMetricsCache(ttl time.Duration, maxSize int64, cleanupInterval time.Dura
 	}
 }
 
-// Get retrieves data from the cache if it exists and hasn't expired
+// Get retrieves data stored under key from the cache if it exists and hasn't expired
 // Returns (data, true) if cache hit, (nil, false) if cache miss or expired
-func (c *MetricsCache) Get() (interface{}, bool) {
+func (c *MetricsCache) Get(key string) (interface{}, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	// Check if cache is empty or expired
-	if c.data == nil || time.Now().After(c.expiration) {
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiration) {
 		return nil, false
 	}
 
-	return c.data, true
+	return entry.data, true
 }
 
-// Set stores data in the cache with the configured TTL
-func (c *MetricsCache) Set(data interface{}) {
+// Set stores data under key (see Key) in the cache with the configured TTL,
+// replacing any previous entry for that key. Entries under other keys are
+// left alone. With a maximum size configured, data whose estimated size does
+// not fit even after dropping expired entries is not cached; the previous
+// entry for key is dropped too, so outdated data is never served instead.
+func (c *MetricsCache) Set(key string, data interface{}) {
+	size := estimateSize(data)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.data = data
-	c.expiration = time.Now().Add(c.ttl)
+	c.remove(key)
+	if c.maxSize > 0 && c.currentSize+size > c.maxSize {
+		c.removeExpired(time.Now())
+		if c.currentSize+size > c.maxSize {
+			slog.Warn("Not caching data larger than the free cache size",
+				"size", size,
+				"current_size", c.currentSize,
+				"max_size", c.maxSize,
+			)
+			return
+		}
+	}
+	c.entries[key] = cacheEntry{data: data, expiration: time.Now().Add(c.ttl), size: size}
+	c.currentSize += size
+}
+
+// estimateSize estimates the memory held by data as the length of its JSON
+// encoding. Data that cannot be encoded counts as 0 bytes.
+func estimateSize(data interface{}) int64 {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return 0
+	}
+	return int64(len(raw))
+}
+
+// remove deletes the entry stored under key and releases its size. The caller
+// must hold the write lock.
+func (c *MetricsCache) remove(key string) {
+	if entry, ok := c.entries[key]; ok {
+		c.currentSize -= entry.size
+		delete(c.entries, key)
+	}
 }
 
-// IsExpired checks if the cache has expired without retrieving data
+// removeExpired deletes all entries expired at now. The caller must hold the
+// write lock.
+func (c *MetricsCache) removeExpired(now time.Time) {
+	for key, entry := range c.entries {
+		if now.After(entry.expiration) {
+			c.remove(key)
+		}
+	}
+}
+
+// IsExpired reports whether the cache holds no unexpired entry, without
+// retrieving data
 func (c *MetricsCache) IsExpired() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	return c.data == nil || time.Now().After(c.expiration)
+	now := time.Now()
+	for _, entry := range c.entries {
+		if !now.After(entry.expiration) {
+			return false
+		}
+	}
+	return true
 }
 
 // Clear removes all data from the cache
@@ -63,15 +148,29 @@ func (c *MetricsCache) Clear() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.data = nil
-	c.expiration = time.Time{}
+	c.entries = make(map[string]cacheEntry)
+	c.currentSize = 0
+}
+
+// Backend returns the name of the storage backend holding the entries
+func (c *MetricsCache) Backend() string {
+	return "memory"
 }
 
 // TTL returns the configured time-to-live duration
 func (c *MetricsCache) TTL() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.ttl
 }
 
+// SetTTL changes the time-to-live applied to data stored from now on
+func (c *MetricsCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
 // MaxSize returns the configured maximum cache size in bytes
 func (c *MetricsCache) MaxSize() int64 {
 	return c.maxSize
@@ -105,17 +204,36 @@ func (c *MetricsCache) Cleanup() bool {
 		return false
 	}
 
-	// Check if cache has expired
-	if c.data != nil && now.After(c.expiration) {
-		c.data = nil
-		c.expiration = time.Time{}
-		c.currentSize = 0
-	}
+	c.removeExpired(now)
 
 	c.lastCleanup = now
 	return true
 }
 
+// RunCleanup drops expired entries every cleanup interval until ctx is
+// cancelled, so their memory is released even when no scrape reads the cache.
+// It returns at once without a positive cleanup interval.
+func (c *MetricsCache) RunCleanup(ctx context.Context) {
+	if c.cleanupInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			c.removeExpired(now)
+			c.lastCleanup = now
+			c.mu.Unlock()
+		}
+	}
+}
+
 // ShouldCleanup returns true if cleanup should be performed based on the interval
 func (c *MetricsCache) ShouldCleanup() bool {
 	c.mu.RLock()
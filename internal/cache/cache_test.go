@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entryCount returns the number of entries in c, including expired ones not
+// yet cleaned up
+func entryCount(c *MetricsCache) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+func TestMetricsCacheMaxSize(t *testing.T) {
+	c := NewMetricsCache(time.Minute, 64, time.Minute)
+
+	c.Set("small", []string{"box"})
+	if _, found := c.Get("small"); !found {
+		t.Fatal("expected data within the max size to be cached")
+	}
+	if got := c.CurrentSize(); got != int64(len(`["box"]`)) {
+		t.Errorf("expected the current size to be the JSON size, got %d", got)
+	}
+
+	c.Set("large", []string{strings.Repeat("x", 100)})
+	if _, found := c.Get("large"); found {
+		t.Error("expected data over the max size not to be cached")
+	}
+	if _, found := c.Get("small"); !found {
+		t.Error("expected entries under other keys to be kept")
+	}
+
+	// Replacing an entry with oversized data drops the outdated entry
+	c.Set("small", []string{strings.Repeat("x", 100)})
+	if _, found := c.Get("small"); found {
+		t.Error("expected a miss after oversized data replaced an entry")
+	}
+	if got := c.CurrentSize(); got != 0 {
+		t.Errorf("expected the size to be released, got %d", got)
+	}
+}
+
+func TestMetricsCacheMaxSizeDropsExpired(t *testing.T) {
+	c := NewMetricsCache(time.Millisecond, 64, time.Minute)
+
+	c.Set("old", []string{strings.Repeat("x", 40)})
+	c.SetTTL(time.Minute)
+	time.Sleep(5 * time.Millisecond)
+
+	// Only fits once the expired entry is dropped
+	c.Set("new", []string{strings.Repeat("y", 40)})
+	if _, found := c.Get("new"); !found {
+		t.Error("expected expired entries to make room for new data")
+	}
+	if got := entryCount(c); got != 1 {
+		t.Errorf("expected the expired entry to be dropped, got %d entries", got)
+	}
+}
+
+func TestMetricsCacheUnlimitedSize(t *testing.T) {
+	c := NewMetricsCache(time.Minute, 0, time.Minute)
+
+	c.Set("large", []string{strings.Repeat("x", 10000)})
+	if _, found := c.Get("large"); !found {
+		t.Error("expected no size limit with a max size of 0")
+	}
+	c.Clear()
+	if got := c.CurrentSize(); got != 0 {
+		t.Errorf("expected Clear to reset the size, got %d", got)
+	}
+}
+
+func TestMetricsCacheRunCleanup(t *testing.T) {
+	c := NewMetricsCache(time.Millisecond, 0, 20*time.Millisecond)
+	c.Set("expired", []string{"box"})
+	c.SetTTL(time.Minute)
+	c.Set("fresh", []string{"box"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.RunCleanup(ctx)
+		close(done)
+	}()
+
+	// The expired entry is purged without any Get
+	deadline := time.Now().Add(time.Second)
+	for entryCount(c) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the expired entry to be purged, got %d entries", entryCount(c))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := c.CurrentSize(); got != int64(len(`["box"]`)) {
+		t.Errorf("expected the purged entry's size to be released, got %d", got)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected RunCleanup to return after cancellation")
+	}
+}
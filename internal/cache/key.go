@@ -0,0 +1,17 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Key derives a cache key from an API token and the endpoint it is used
+// against. The token is hashed with SHA-256 so the raw credential is never
+// stored in the cache, and only the first 16 bytes of the digest are kept,
+// which is plenty to keep accounts apart. The endpoint (base URL, region, ...)
+// is appended in clear text so entries for the same account on different
+// endpoints do not collide either.
+func Key(token, endpoint string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16]) + "|" + endpoint
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	const token = "super-secret-token"
+
+	key := Key(token, "https://api.hetzner.com/v1")
+	if strings.Contains(key, token) {
+		t.Errorf("key %q contains the raw token", key)
+	}
+	if key != Key(token, "https://api.hetzner.com/v1") {
+		t.Error("expected the same token and endpoint to produce the same key")
+	}
+	if key == Key("other-token", "https://api.hetzner.com/v1") {
+		t.Error("expected different tokens to produce different keys")
+	}
+	if key == Key(token, "https://example.com/v1") {
+		t.Error("expected different endpoints to produce different keys")
+	}
+}
+
+func TestMetricsCacheKeyMismatch(t *testing.T) {
+	c := NewMetricsCache(time.Minute, 0, 0)
+	c.Set(Key("token-a", "endpoint"), "data")
+
+	if _, found := c.Get(Key("token-b", "endpoint")); found {
+		t.Error("expected a miss for an entry stored under another account's key")
+	}
+	if data, found := c.Get(Key("token-a", "endpoint")); !found || data != "data" {
+		t.Errorf("expected a hit for the matching key, got %v, %v", data, found)
+	}
+}
+
+func TestMetricsCacheCleanupDropsExpiredEntries(t *testing.T) {
+	c := NewMetricsCache(time.Minute, 0, 0)
+	c.SetTTL(time.Millisecond)
+	c.Set("stale", "data")
+	c.SetTTL(time.Minute)
+	c.Set("fresh", "data")
+	time.Sleep(5 * time.Millisecond)
+
+	if !c.Cleanup() {
+		t.Fatal("expected cleanup to run with a zero interval")
+	}
+	if got := entryCount(c); got != 1 {
+		t.Errorf("expected only the fresh entry to remain, got %d entries", got)
+	}
+}
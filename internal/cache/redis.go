@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces the exporter's entries in a shared Redis database
+const redisKeyPrefix = "storagebox_exporter:"
+
+// redisTimeout bounds connecting to Redis and each command, so an unreachable
+// Redis slows a scrape down by at most this much before it falls back to the API
+const redisTimeout = 2 * time.Second
+
+// RedisCache stores entries in Redis as JSON, so replicas of the exporter can
+// share cached API data. Entries expire in Redis after the TTL. Redis errors
+// are logged and treated as cache misses; the cache is an optimization and
+// never fails a scrape. The outcome of the last command and the number of
+// failed commands are kept for the exporter's Redis health metrics.
+type RedisCache struct {
+	client *redis.Client
+	decode func([]byte) (interface{}, error)
+
+	up       atomic.Bool
+	failures atomic.Uint64
+
+	mu  sync.Mutex
+	ttl time.Duration
+}
+
+// NewRedisCache creates a Redis cache from a URL of the form
+// redis://[[user]:password@]host[:port][/db]. decode turns the JSON of a
+// stored value back into the type that was passed to Set. No connection is
+// made until the first command.
+func NewRedisCache(redisURL string, ttl time.Duration, decode func([]byte) (interface{}, error)) (*RedisCache, error) {
+	u, err := url.Parse(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid Redis URL: unsupported scheme %q, expected redis://", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Redis URL: missing host")
+	}
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+
+	opts.DialTimeout = redisTimeout
+	opts.ReadTimeout = redisTimeout
+	opts.WriteTimeout = redisTimeout
+	// A failed command is a cache miss; retrying would only delay the
+	// fallback to the API
+	opts.MaxRetries = -1
+	opts.DialerRetries = 1
+	// CLIENT SETINFO is unknown to Redis before 7.2
+	opts.DisableIdentity = true
+
+	redis.SetLogger(redisLogger{})
+	return &RedisCache{
+		client: redis.NewClient(opts),
+		decode: decode,
+		ttl:    ttl,
+	}, nil
+}
+
+// redisLogger routes the internal logs of the Redis client to slog at debug
+// level. Failed commands are logged as warnings by RedisCache itself.
+type redisLogger struct{}
+
+// Printf implements the logger interface of the Redis client
+func (redisLogger) Printf(ctx context.Context, format string, v ...interface{}) {
+	slog.DebugContext(ctx, "Redis client: "+fmt.Sprintf(format, v...))
+}
+
+// Get retrieves data stored under key if it exists and hasn't expired
+func (c *RedisCache) Get(key string) (interface{}, bool) {
+	raw, err := c.client.Get(context.Background(), redisKeyPrefix+key).Bytes()
+	c.record(err)
+	if errors.Is(err, redis.Nil) {
+		// No such key or expired
+		return nil, false
+	}
+	if err != nil {
+		slog.Warn("Redis cache read failed", "error", err)
+		return nil, false
+	}
+	data, err := c.decode(raw)
+	if err != nil {
+		slog.Warn("Discarding undecodable Redis cache entry", "error", err)
+		return nil, false
+	}
+	return data, true
+}
+
+// Set stores data as JSON under key with the configured TTL
+func (c *RedisCache) Set(key string, data interface{}) {
+	ttl := c.TTL()
+	if ttl <= 0 {
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		slog.Warn("Failed to encode Redis cache entry", "error", err)
+		return
+	}
+	if err := c.record(c.client.Set(context.Background(), redisKeyPrefix+key, raw, ttl).Err()); err != nil {
+		slog.Warn("Redis cache write failed", "error", err)
+	}
+}
+
+// Clear removes the exporter's entries from Redis. Other keys in the same
+// database are left alone.
+func (c *RedisCache) Clear() {
+	ctx := context.Background()
+	iter := c.client.Scan(ctx, 0, redisKeyPrefix+"*", 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := c.record(iter.Err()); err != nil {
+		slog.Warn("Redis cache clear failed", "error", err)
+		return
+	}
+	if len(keys) == 0 {
+		return
+	}
+	if err := c.record(c.client.Del(ctx, keys...).Err()); err != nil {
+		slog.Warn("Redis cache clear failed", "error", err)
+	}
+}
+
+// Ping checks that Redis is reachable and accepts the credentials
+func (c *RedisCache) Ping() error {
+	return c.record(c.client.Ping(context.Background()).Err())
+}
+
+// record tracks the outcome of a Redis command and returns its error. A
+// missing key is a successful command.
+func (c *RedisCache) record(err error) error {
+	failed := err != nil && !errors.Is(err, redis.Nil)
+	c.up.Store(!failed)
+	if failed {
+		c.failures.Add(1)
+	}
+	return err
+}
+
+// Up reports whether the last Redis command succeeded
+func (c *RedisCache) Up() bool {
+	return c.up.Load()
+}
+
+// Errors returns the number of failed Redis commands so far
+func (c *RedisCache) Errors() uint64 {
+	return c.failures.Load()
+}
+
+// Backend returns the name of the storage backend holding the entries
+func (c *RedisCache) Backend() string {
+	return "redis"
+}
+
+// TTL returns the configured time-to-live duration
+func (c *RedisCache) TTL() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ttl
+}
+
+// SetTTL changes the time-to-live applied to data stored from now on
+func (c *RedisCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
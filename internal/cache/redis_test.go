@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// decodeStrings decodes a JSON-encoded []string, standing in for the storage
+// box decoder of the collector
+func decodeStrings(raw []byte) (interface{}, error) {
+	var values []string
+	err := json.Unmarshal(raw, &values)
+	return values, err
+}
+
+func TestRedisCacheSetGet(t *testing.T) {
+	server := miniredis.RunT(t)
+	c, err := NewRedisCache("redis://"+server.Addr(), time.Minute, decodeStrings)
+	if err != nil {
+		t.Fatalf("NewRedisCache() unexpected error: %v", err)
+	}
+
+	if _, found := c.Get("account"); found {
+		t.Fatal("expected a miss on an empty cache")
+	}
+	c.Set("account", []string{"box-1", "box-2"})
+
+	data, found := c.Get("account")
+	if !found {
+		t.Fatal("expected a hit after Set")
+	}
+	if got := data.([]string); strings.Join(got, ",") != "box-1,box-2" {
+		t.Errorf("expected the stored value, got %v", got)
+	}
+	if keys := server.Keys(); len(keys) != 1 || keys[0] != redisKeyPrefix+"account" {
+		t.Errorf("expected the entry under the exporter's prefix, got %v", keys)
+	}
+	if c.Backend() != "redis" {
+		t.Errorf("expected backend redis, got %q", c.Backend())
+	}
+}
+
+func TestRedisCacheExpiry(t *testing.T) {
+	server := miniredis.RunT(t)
+	c, err := NewRedisCache("redis://"+server.Addr(), 50*time.Millisecond, decodeStrings)
+	if err != nil {
+		t.Fatalf("NewRedisCache() unexpected error: %v", err)
+	}
+
+	c.Set("account", []string{"box"})
+	if got := server.TTL(redisKeyPrefix + "account"); got != 50*time.Millisecond {
+		t.Fatalf("expected the entry to expire after the TTL, got a TTL of %v", got)
+	}
+	server.FastForward(100 * time.Millisecond)
+	if _, found := c.Get("account"); found {
+		t.Error("expected the entry to expire after the TTL")
+	}
+}
+
+func TestRedisCacheClear(t *testing.T) {
+	server := miniredis.RunT(t)
+	if err := server.Set("unrelated", "kept"); err != nil {
+		t.Fatalf("failed to set key: %v", err)
+	}
+	c, err := NewRedisCache("redis://"+server.Addr(), time.Minute, decodeStrings)
+	if err != nil {
+		t.Fatalf("NewRedisCache() unexpected error: %v", err)
+	}
+
+	c.Set("a", []string{"1"})
+	c.Set("b", []string{"2"})
+	c.Clear()
+
+	if _, found := c.Get("a"); found {
+		t.Error("expected entries to be cleared")
+	}
+	if keys := server.Keys(); len(keys) != 1 || keys[0] != "unrelated" {
+		t.Errorf("expected only keys outside the prefix to remain, got %v", keys)
+	}
+}
+
+func TestRedisCacheAuth(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("s3cret")
+
+	c, err := NewRedisCache("redis://:s3cret@"+server.Addr()+"/2", time.Minute, decodeStrings)
+	if err != nil {
+		t.Fatalf("NewRedisCache() unexpected error: %v", err)
+	}
+	if err := c.Ping(); err != nil {
+		t.Fatalf("Ping() unexpected error: %v", err)
+	}
+	c.Set("account", []string{"box"})
+	if keys := server.DB(2).Keys(); len(keys) != 1 || keys[0] != redisKeyPrefix+"account" {
+		t.Errorf("expected the entry in the database from the URL, got %v", keys)
+	}
+
+	wrong, err := NewRedisCache("redis://:wrong@"+server.Addr(), time.Minute, decodeStrings)
+	if err != nil {
+		t.Fatalf("NewRedisCache() unexpected error: %v", err)
+	}
+	if err := wrong.Ping(); err == nil {
+		t.Error("expected Ping to fail with a wrong password")
+	}
+}
+
+func TestRedisCacheUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	c, err := NewRedisCache("redis://"+addr, time.Minute, decodeStrings)
+	if err != nil {
+		t.Fatalf("NewRedisCache() unexpected error: %v", err)
+	}
+	// Redis errors are cache misses, never failures
+	c.Set("account", []string{"box"})
+	if _, found := c.Get("account"); found {
+		t.Error("expected a miss while Redis is unreachable")
+	}
+	if err := c.Ping(); err == nil {
+		t.Error("expected Ping to fail while Redis is unreachable")
+	}
+}
+
+func TestRedisCacheHealth(t *testing.T) {
+	server := miniredis.RunT(t)
+	c, err := NewRedisCache("redis://"+server.Addr(), time.Minute, decodeStrings)
+	if err != nil {
+		t.Fatalf("NewRedisCache() unexpected error: %v", err)
+	}
+
+	// A miss is a successful command
+	if _, found := c.Get("account"); found {
+		t.Fatal("expected a miss on an empty cache")
+	}
+	if !c.Up() || c.Errors() != 0 {
+		t.Fatalf("expected Redis up without errors after a miss, got up=%v errors=%d", c.Up(), c.Errors())
+	}
+
+	server.SetError("LOADING Redis is loading the dataset in memory")
+	c.Set("account", []string{"box"})
+	if _, found := c.Get("account"); found {
+		t.Error("expected a miss while Redis fails")
+	}
+	if c.Up() || c.Errors() != 2 {
+		t.Errorf("expected Redis down with the failed Set and Get counted, got up=%v errors=%d", c.Up(), c.Errors())
+	}
+
+	server.SetError("")
+	if err := c.Ping(); err != nil {
+		t.Fatalf("Ping() unexpected error: %v", err)
+	}
+	if !c.Up() || c.Errors() != 2 {
+		t.Errorf("expected Redis up again with the errors kept, got up=%v errors=%d", c.Up(), c.Errors())
+	}
+}
+
+func TestNewRedisCacheInvalidURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "wrong scheme", url: "http://localhost:6379"},
+		{name: "missing host", url: "redis://"},
+		{name: "invalid database", url: "redis://localhost:6379/cache"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRedisCache(tt.url, time.Minute, decodeStrings); err == nil {
+				t.Errorf("expected an error for %q", tt.url)
+			}
+		})
+	}
+}
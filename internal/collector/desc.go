@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// descInfo is what a descriptor was created with, which prometheus.Desc does
+// not expose
+type descInfo struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// descTable creates descriptors and remembers their name, help and variable
+// labels, so metrics can be looked up by descriptor when filtering, renaming
+// and printing the schema
+type descTable struct {
+	mu    sync.RWMutex
+	infos map[*prometheus.Desc]descInfo
+}
+
+// newDescTable creates an empty descTable
+func newDescTable() *descTable {
+	return &descTable{infos: make(map[*prometheus.Desc]descInfo)}
+}
+
+// desc creates a descriptor without constant labels, like prometheus.NewDesc
+func (t *descTable) desc(name, help string, labels []string) *prometheus.Desc {
+	desc := prometheus.NewDesc(name, help, labels, nil)
+	t.record(desc, name, help, labels)
+	return desc
+}
+
+// counter creates a counter without labels, like prometheus.NewCounter
+func (t *descTable) counter(opts prometheus.CounterOpts) prometheus.Counter {
+	counter := prometheus.NewCounter(opts)
+	t.record(counter.Desc(), prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name), opts.Help, nil)
+	return counter
+}
+
+// record remembers what desc was created with
+func (t *descTable) record(desc *prometheus.Desc, name, help string, labels []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.infos[desc] = descInfo{name: name, help: help, labels: append([]string{}, labels...)}
+}
+
+// info returns what desc was created with. ok is false for descriptors not
+// created through the table.
+func (t *descTable) info(desc *prometheus.Desc) (descInfo, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	info, ok := t.infos[desc]
+	return info, ok
+}
+
+// name returns the fully-qualified metric name of desc, or "" for descriptors
+// not created through the table
+func (t *descTable) name(desc *prometheus.Desc) string {
+	info, _ := t.info(desc)
+	return info.name
+}
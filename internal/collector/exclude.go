@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SetExcludedMetrics suppresses the given metrics by exact name, e.g.
+// storagebox_access_zfs_enabled. Unknown names are rejected so typos don't
+// silently keep a metric.
+func (c *StorageBoxCollector) SetExcludedMetrics(names []string) error {
+	known := make(map[string]bool)
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(descs)
+		close(descs)
+	}()
+	for desc := range descs {
+		known[c.descs.name(desc)] = true
+	}
+
+	excluded := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("unknown metric %q", name)
+		}
+		excluded[name] = true
+	}
+	c.excluded = excluded
+	return nil
+}
+
+// excludeFilter returns a channel that forwards metrics to ch except excluded
+// ones, and a function that must be called once all metrics have been sent.
+// Without excluded metrics ch itself is returned.
+func (c *StorageBoxCollector) excludeFilter(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func()) {
+	if len(c.excluded) == 0 {
+		return ch, func() {}
+	}
+
+	filtered := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range filtered {
+			if !c.excluded[c.descs.name(m.Desc())] {
+				ch <- m
+			}
+		}
+	}()
+	return filtered, func() {
+		close(filtered)
+		<-done
+	}
+}
@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestExcludedMetrics(t *testing.T) {
+	reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+	if err := collector.SetExcludedMetrics([]string{"storagebox_access_zfs_enabled", "storagebox_exporter_scrape_errors_total"}); err != nil {
+		t.Fatalf("SetExcludedMetrics() unexpected error = %v", err)
+	}
+
+	if got := gaugeValue(t, reg, "storagebox_access_zfs_enabled"); got != -1 {
+		t.Errorf("expected storagebox_access_zfs_enabled to be excluded, got %v", got)
+	}
+	if got := counterValue(t, reg, "storagebox_exporter_scrape_errors_total"); got != -1 {
+		t.Errorf("expected storagebox_exporter_scrape_errors_total to be excluded, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_access_ssh_enabled"); got == -1 {
+		t.Error("expected storagebox_access_ssh_enabled to remain")
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != 1 {
+		t.Errorf("expected storagebox_exporter_up=1, got %v", got)
+	}
+
+	// The incremental endpoint applies the same filter
+	since := prometheus.NewRegistry()
+	since.MustRegister(collector.Since(context.Background(), time.Time{}))
+	if got := gaugeValue(t, since, "storagebox_access_zfs_enabled"); got != -1 {
+		t.Errorf("expected storagebox_access_zfs_enabled to be excluded from the since collector, got %v", got)
+	}
+	if got := gaugeValue(t, since, "storagebox_disk_usage_bytes"); got == -1 {
+		t.Error("expected storagebox_disk_usage_bytes to remain in the since collector")
+	}
+}
+
+func TestExcludedMetricsUnknownName(t *testing.T) {
+	_, collector := newMockRegistry(t, mockStorageBoxResponse())
+	if err := collector.SetExcludedMetrics([]string{"storagebox_access_zfs"}); err == nil {
+		t.Error("SetExcludedMetrics() expected error for an unknown metric name")
+	}
+}
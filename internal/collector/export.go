@@ -0,0 +1,58 @@
+package collector
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// csvHeader lists the columns written by WriteCSV
+var csvHeader = []string{
+	"id", "name", "type", "quota_bytes", "usage_bytes", "location", "status", "delete_protection", "snapshot_plan_enabled",
+}
+
+// WriteCSV writes the storage boxes as CSV with a header row and one row per
+// box, for spreadsheet reports. Like /metrics/since, it serves the cached
+// boxes or those of the last fetch and leaves the scrape state untouched; the
+// API is only called, with ctx, before any storage boxes have been fetched.
+// Sizes are always in bytes, regardless of the configured size unit.
+func (c *StorageBoxCollector) WriteCSV(ctx context.Context, w io.Writer) error {
+	boxes, err := c.storedBoxes(ctx, "export")
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, box := range boxes {
+		snapshotPlan := box.SnapshotPlan != nil && box.SnapshotPlan.Enabled
+		if err := cw.Write([]string{
+			formatInt64(box.ID),
+			csvText(box.Name),
+			csvText(box.StorageBoxType.Name),
+			formatInt64(int64(box.StorageBoxType.Size)),
+			formatInt64(int64(box.Stats.Size)),
+			csvText(box.Location.Name),
+			csvText(box.Status),
+			strconv.FormatBool(box.Protection.Delete),
+			strconv.FormatBool(snapshotPlan),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvText escapes a text cell that a spreadsheet would evaluate as a formula,
+// such as a box named "=HYPERLINK(...)", by prefixing it with a quote
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
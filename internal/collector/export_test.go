@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestWriteCSVLeavesStateUntouched(t *testing.T) {
+	var calls atomic.Int32
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"storage_boxes": [{"id": 1, "name": "box", "status": "active"}]}`))
+	})
+	defer server.Close()
+
+	c := NewStorageBoxCollector(client, time.Minute, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	if _, err := reg.Gather(); err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+
+	for range 2 {
+		if err := c.WriteCSV(context.Background(), &bytes.Buffer{}); err != nil {
+			t.Fatalf("WriteCSV() unexpected error = %v", err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected exports to reuse the cached boxes, got %d API calls", got)
+	}
+
+	// Every counterValue call gathers, i.e. scrapes, once more: the first
+	// one counts the first cache hit, the third one the fourth scrape
+	if got := counterValue(t, reg, "storagebox_exporter_cache_hits_total"); got != 1 {
+		t.Errorf("expected exports not to count cache hits, got %v", got)
+	}
+	if got := counterValue(t, reg, "storagebox_exporter_cache_misses_total"); got != 1 {
+		t.Errorf("expected exports not to count cache misses, got %v", got)
+	}
+	if got := counterValue(t, reg, "storagebox_exporter_scrapes_total"); got != 4 {
+		t.Errorf("expected exports not to count as scrapes, got %v", got)
+	}
+}
+
+func TestWriteCSVEscapesFormulas(t *testing.T) {
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"storage_boxes": [
+			{"id": 1, "name": "=HYPERLINK(\"http://example.com\")", "status": "active", "storage_box_type": {"name": "+bx11"}, "location": {"name": "-fsn1"}},
+			{"id": 2, "name": "@box", "status": "active"},
+			{"id": 3, "name": "box=1", "status": "active"}
+		]}`))
+	})
+	defer server.Close()
+
+	var buf bytes.Buffer
+	if err := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{}).WriteCSV(context.Background(), &buf); err != nil {
+		t.Fatalf("WriteCSV() unexpected error = %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("expected a header and three rows, got %d rows", len(rows))
+	}
+
+	tests := []struct {
+		row, col int
+		want     string
+	}{
+		{row: 1, col: 1, want: `'=HYPERLINK("http://example.com")`},
+		{row: 1, col: 2, want: "'+bx11"},
+		{row: 1, col: 5, want: "'-fsn1"},
+		{row: 2, col: 1, want: "'@box"},
+		{row: 3, col: 1, want: "box=1"},
+		{row: 1, col: 0, want: "1"},
+	}
+	for _, tt := range tests {
+		if got := rows[tt.row][tt.col]; got != tt.want {
+			t.Errorf("expected cell %q in row %d, got %q", tt.want, tt.row, got)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"runtime"
+	"time"
+)
+
+// Health describes the exporter for verbose health checks. It carries no
+// configuration beyond the cache backend, so it never exposes secrets.
+type Health struct {
+	Version      string `json:"version"`
+	Commit       string `json:"commit"`
+	GoVersion    string `json:"go_version"`
+	CacheBackend string `json:"cache_backend"`
+	// LastScrape is "success" or "error" for the outcome of the last scrape,
+	// "none" before the first scrape
+	LastScrape string `json:"last_scrape"`
+	// LastScrapeSuccess is the time of the last scrape that fetched storage
+	// boxes without error, omitted until one has
+	LastScrapeSuccess *time.Time `json:"last_scrape_success,omitempty"`
+}
+
+// Health returns the exporter's version, cache backend and last scrape outcome
+func (c *StorageBoxCollector) Health() Health {
+	h := Health{
+		Version:      c.buildInfoData.Version,
+		Commit:       c.buildInfoData.Commit,
+		GoVersion:    runtime.Version(),
+		CacheBackend: c.cache.Backend(),
+		LastScrape:   "none",
+	}
+	if status, ok := c.lastScrapeStatus.Load().(string); ok {
+		h.LastScrape = status
+	}
+	if lastSuccess := c.lastSuccess.Load(); lastSuccess > 0 {
+		t := time.Unix(0, lastSuccess).UTC()
+		h.LastScrapeSuccess = &t
+	}
+	return h
+}
@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/hetzner"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errNoPollData is returned in poll mode until the first poll has completed
+var errNoPollData = errors.New("no storage box data has been polled yet")
+
+// SetPollInterval decouples API calls from scrapes: scrapes serve the result of
+// the last background poll instead of calling the API. RunPoller must be
+// started for data to be fetched. An interval of 0 or below disables polling.
+func (c *StorageBoxCollector) SetPollInterval(interval time.Duration) {
+	if interval < 0 {
+		interval = 0
+	}
+	c.pollInterval = interval
+}
+
+// RunPoller fetches storage boxes immediately and then every poll interval
+// until ctx is cancelled. It returns at once when polling is disabled.
+func (c *StorageBoxCollector) RunPoller(ctx context.Context) {
+	if c.pollInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		c.poll()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll runs a single poll cycle, recording its outcome for the next scrapes.
+// No API call is made while paused.
+func (c *StorageBoxCollector) poll() {
+	if c.paused.Load() {
+		return
+	}
+
+	_, _, err := c.fetchFromAPI(context.Background(), "poll")
+	if err != nil {
+		slog.Warn("Background poll failed", "error", err)
+	}
+
+	now := time.Now().UnixNano()
+	c.stateMu.Lock()
+	c.lastPollErr = err
+	c.stateMu.Unlock()
+	if err == nil {
+		c.lastPollData.Store(now)
+	}
+	c.lastPoll.Store(now)
+}
+
+// SetPollTimestamps makes per-box metrics carry the time of the poll that
+// fetched their data as explicit timestamp in poll mode, instead of being
+// stamped with the scrape time by Prometheus.
+func (c *StorageBoxCollector) SetPollTimestamps(enabled bool) {
+	c.pollTimestamps = enabled
+}
+
+// pollTimestampFilter returns a channel that forwards metrics to ch stamped
+// with the time of the last successful poll, and a function that must be
+// called once all metrics have been sent. Outside poll mode, with poll
+// timestamps disabled for the collector or the scrape, or before the first
+// successful poll, ch itself is returned.
+func (c *StorageBoxCollector) pollTimestampFilter(ch chan<- prometheus.Metric, opts ScrapeOptions) (chan<- prometheus.Metric, func()) {
+	polled := c.lastPollData.Load()
+	if !c.pollTimestamps || opts.NoPollTimestamps || c.pollInterval <= 0 || polled == 0 {
+		return ch, func() {}
+	}
+
+	ts := time.Unix(0, polled)
+	stamped := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range stamped {
+			ch <- prometheus.NewMetricWithTimestamp(ts, m)
+		}
+	}()
+	return stamped, func() {
+		close(stamped)
+		<-done
+	}
+}
+
+// polledBoxes returns the storage boxes fetched by the last poll, or its error
+func (c *StorageBoxCollector) polledBoxes() ([]hetzner.StorageBox, error) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.lastPollErr != nil {
+		return nil, c.lastPollErr
+	}
+	if c.lastBoxes == nil {
+		return nil, errNoPollData
+	}
+	return c.lastBoxes, nil
+}
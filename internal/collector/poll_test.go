@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPollMode(t *testing.T) {
+	var calls atomic.Int32
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	collector.SetPollInterval(time.Minute)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	// Before the first poll scrapes report the exporter as down
+	if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != 0 {
+		t.Errorf("expected up=0 before the first poll, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_poll_interval_seconds"); got != 60 {
+		t.Errorf("expected poll interval 60, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_last_poll_timestamp_seconds"); got != -1 {
+		t.Errorf("expected no last poll timestamp before the first poll, got %v", got)
+	}
+
+	collector.poll()
+	first := gaugeValue(t, reg, "storagebox_exporter_last_poll_timestamp_seconds")
+	if first <= 0 {
+		t.Fatalf("expected a last poll timestamp after polling, got %v", first)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != 1 {
+		t.Errorf("expected up=1 after a successful poll, got %v", got)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	collector.poll()
+	if second := gaugeValue(t, reg, "storagebox_exporter_last_poll_timestamp_seconds"); second <= first {
+		t.Errorf("expected the last poll timestamp to advance, got %v after %v", second, first)
+	}
+
+	// Scrapes never call the API themselves
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected one API call per poll, got %d", got)
+	}
+}
+
+func TestPollModeFailure(t *testing.T) {
+	var fail atomic.Bool
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	collector.SetPollInterval(time.Minute)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	collector.poll()
+	fail.Store(true)
+	collector.poll()
+
+	// A failed poll is not hidden behind the previous result
+	if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != 0 {
+		t.Errorf("expected up=0 after a failed poll, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_disk_usage_bytes"); got != -1 {
+		t.Errorf("expected no per-box metrics after a failed poll, got %v", got)
+	}
+}
+
+func TestRunPoller(t *testing.T) {
+	_, collector := newMockRegistry(t, mockStorageBoxResponse())
+	collector.SetPollInterval(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		collector.RunPoller(ctx)
+		close(done)
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for first := int64(0); ; {
+		lastPoll := collector.lastPoll.Load()
+		if first == 0 {
+			first = lastPoll
+		} else if lastPoll > first {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("poller did not run on schedule")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("poller did not stop after cancellation")
+	}
+}
+
+func TestPollTimestamps(t *testing.T) {
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	collector.SetPollInterval(time.Minute)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	// timestamps returns the timestamp in milliseconds of the first sample of
+	// each metric, 0 for none
+	timestamps := func() map[string]int64 {
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("Gather() unexpected error: %v", err)
+		}
+		got := make(map[string]int64)
+		for _, mf := range families {
+			got[mf.GetName()] = mf.GetMetric()[0].GetTimestampMs()
+		}
+		return got
+	}
+
+	collector.poll()
+	if got := timestamps()["storagebox_disk_quota_bytes"]; got != 0 {
+		t.Errorf("expected no timestamp with poll timestamps disabled, got %d", got)
+	}
+
+	collector.SetPollTimestamps(true)
+	time.Sleep(10 * time.Millisecond)
+	collector.poll()
+	pollTime := time.Unix(0, collector.lastPollData.Load()).UnixMilli()
+
+	got := timestamps()
+	if got["storagebox_disk_quota_bytes"] != pollTime {
+		t.Errorf("expected the poll time %d as timestamp, got %d", pollTime, got["storagebox_disk_quota_bytes"])
+	}
+	if got["storagebox_info"] != pollTime {
+		t.Errorf("expected the poll time %d on every per-box metric, got %d", pollTime, got["storagebox_info"])
+	}
+	if got["storagebox_exporter_up"] != 0 {
+		t.Errorf("expected exporter metrics without timestamp, got %d", got["storagebox_exporter_up"])
+	}
+}
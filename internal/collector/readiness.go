@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// authFailureThreshold is the number of consecutive authentication
+	// failures after which the exporter reports itself not ready
+	authFailureThreshold = 2
+
+	// failureWindow is how long API calls may keep failing for any reason
+	// before the exporter reports itself not ready
+	failureWindow = 5 * time.Minute
+)
+
+// readinessPolicy weighs API errors by type to decide readiness. Sustained
+// authentication failures need operator action and mark the exporter not
+// ready, while transient server and rate limit errors only degrade it until
+// they persist for longer than failureWindow.
+type readinessPolicy struct {
+	mu                  sync.Mutex
+	now                 func() time.Time
+	consecutiveAuthErrs int
+	failingSince        time.Time
+}
+
+// newReadinessPolicy creates a policy that starts out ready
+func newReadinessPolicy() *readinessPolicy {
+	return &readinessPolicy{now: time.Now}
+}
+
+// recordSuccess resets the policy after a successful API call
+func (p *readinessPolicy) recordSuccess() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.consecutiveAuthErrs = 0
+	p.failingSince = time.Time{}
+}
+
+// recordFailure records a failed API call
+func (p *readinessPolicy) recordFailure(authErr bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if authErr {
+		p.consecutiveAuthErrs++
+	} else {
+		p.consecutiveAuthErrs = 0
+	}
+	if p.failingSince.IsZero() {
+		p.failingSince = p.now()
+	}
+}
+
+// ready reports whether the exporter is ready and, if not, why
+func (p *readinessPolicy) ready() (bool, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.consecutiveAuthErrs >= authFailureThreshold {
+		return false, "sustained authentication failures"
+	}
+	if !p.failingSince.IsZero() && p.now().Sub(p.failingSince) >= failureWindow {
+		return false, "API calls failing for more than " + failureWindow.String()
+	}
+	return true, ""
+}
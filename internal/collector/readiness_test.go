@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/hetzner"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestReadinessPolicy(t *testing.T) {
+	authErr := hetzner.NewAPIError(http.StatusUnauthorized, "unauthorized", "")
+	serverErr := hetzner.NewAPIError(http.StatusInternalServerError, "internal error", "")
+	rateLimitErr := hetzner.NewAPIError(http.StatusTooManyRequests, "rate limited", "")
+
+	tests := []struct {
+		name      string
+		errs      []error
+		elapsed   time.Duration
+		wantReady bool
+	}{
+		{name: "no calls yet", wantReady: true},
+		{name: "success", errs: []error{nil}, wantReady: true},
+		{name: "single auth error", errs: []error{authErr}, wantReady: true},
+		{name: "sustained auth errors", errs: []error{authErr, authErr}, wantReady: false},
+		{name: "auth errors interrupted by success", errs: []error{authErr, nil, authErr}, wantReady: true},
+		{name: "brief server errors", errs: []error{serverErr, serverErr, serverErr}, elapsed: time.Minute, wantReady: true},
+		{name: "brief rate limit errors", errs: []error{rateLimitErr, rateLimitErr}, elapsed: time.Minute, wantReady: true},
+		{name: "prolonged server errors", errs: []error{serverErr, serverErr}, elapsed: failureWindow, wantReady: false},
+		{name: "recovered after prolonged failure", errs: []error{serverErr, nil}, elapsed: failureWindow, wantReady: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			p := newReadinessPolicy()
+			p.now = func() time.Time { return now }
+
+			for _, err := range tt.errs {
+				if err == nil {
+					p.recordSuccess()
+				} else {
+					p.recordFailure(hetzner.IsAuthError(err))
+				}
+			}
+			now = now.Add(tt.elapsed)
+
+			ready, reason := p.ready()
+			if ready != tt.wantReady {
+				t.Errorf("ready() = %v (%s), want %v", ready, reason, tt.wantReady)
+			}
+			if !ready && reason == "" {
+				t.Error("expected a reason when not ready")
+			}
+		})
+	}
+}
+
+func TestCollectReadiness(t *testing.T) {
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	if got := gaugeValue(t, reg, "storagebox_exporter_readiness"); got != 1 {
+		t.Errorf("expected readiness 1 after a single auth error, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_readiness"); got != 0 {
+		t.Errorf("expected readiness 0 after sustained auth errors, got %v", got)
+	}
+	if ready, _ := collector.Ready(); ready {
+		t.Error("expected Ready() to report not ready")
+	}
+}
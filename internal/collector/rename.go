@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// labelRenamer renames label names on descriptors and emitted metrics
+type labelRenamer struct {
+	renames map[string]string
+	table   *descTable
+	descs   sync.Map // Original *prometheus.Desc to renamed *prometheus.Desc
+}
+
+// SetLabelRenames renames labels on every metric, e.g. id to box_id, for
+// teams with label naming conventions. Unknown source labels, invalid target
+// names and renames that would give a metric the same label twice are
+// rejected.
+func (c *StorageBoxCollector) SetLabelRenames(renames map[string]string) error {
+	if len(renames) == 0 {
+		c.renamer = nil
+		return nil
+	}
+
+	known := make(map[string]bool)
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.describe(descs)
+		close(descs)
+	}()
+	var all []*prometheus.Desc
+	for desc := range descs {
+		all = append(all, desc)
+		info, _ := c.descs.info(desc)
+		for _, label := range info.labels {
+			known[label] = true
+		}
+	}
+
+	for from, to := range renames {
+		if !known[from] {
+			return fmt.Errorf("unknown label %q", from)
+		}
+		if !labelNamePattern.MatchString(to) || strings.HasPrefix(to, "__") {
+			return fmt.Errorf("invalid label name %q for label %q", to, from)
+		}
+	}
+
+	r := &labelRenamer{renames: renames, table: c.descs}
+	for _, desc := range all {
+		info, _ := c.descs.info(desc)
+		seen := make(map[string]bool)
+		for _, label := range r.labels(info.labels) {
+			if seen[label] {
+				return fmt.Errorf("renaming labels of %s gives duplicate label %q", info.name, label)
+			}
+			seen[label] = true
+		}
+	}
+	c.renamer = r
+	return nil
+}
+
+// labels returns the given label names with renames applied
+func (r *labelRenamer) labels(names []string) []string {
+	renamed := make([]string, len(names))
+	for i, name := range names {
+		renamed[i] = name
+		if to, ok := r.renames[name]; ok {
+			renamed[i] = to
+		}
+	}
+	return renamed
+}
+
+// desc returns desc with renamed labels, built once per descriptor
+func (r *labelRenamer) desc(desc *prometheus.Desc) *prometheus.Desc {
+	if renamed, ok := r.descs.Load(desc); ok {
+		return renamed.(*prometheus.Desc)
+	}
+	info, _ := r.table.info(desc)
+	renamed := r.table.desc(info.name, info.help, r.labels(info.labels))
+	r.descs.Store(desc, renamed)
+	return renamed
+}
+
+// renameDescs sends the descriptors sent by describe to ch with renamed labels
+func (c *StorageBoxCollector) renameDescs(ch chan<- *prometheus.Desc, describe func(chan<- *prometheus.Desc)) {
+	r := c.renamer
+	if r == nil {
+		describe(ch)
+		return
+	}
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		describe(descs)
+		close(descs)
+	}()
+	for desc := range descs {
+		ch <- r.desc(desc)
+	}
+}
+
+// renameFilter returns a channel that forwards metrics to ch with renamed
+// labels, and a function that must be called once all metrics have been sent.
+// Without renames ch itself is returned.
+func (c *StorageBoxCollector) renameFilter(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func()) {
+	r := c.renamer
+	if r == nil {
+		return ch, func() {}
+	}
+
+	renamed := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range renamed {
+			ch <- renamedMetric{Metric: m, desc: r.desc(m.Desc()), renames: r.renames}
+		}
+	}()
+	return renamed, func() {
+		close(renamed)
+		<-done
+	}
+}
+
+// renamedMetric is a metric whose labels are renamed when written
+type renamedMetric struct {
+	prometheus.Metric
+	desc    *prometheus.Desc
+	renames map[string]string
+}
+
+// Desc implements prometheus.Metric
+func (m renamedMetric) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+// Write implements prometheus.Metric
+func (m renamedMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	for _, pair := range out.Label {
+		if to, ok := m.renames[pair.GetName()]; ok {
+			pair.Name = &to
+		}
+	}
+	// Label pairs are expected sorted by name
+	sort.Slice(out.Label, func(i, j int) bool { return out.Label[i].GetName() < out.Label[j].GetName() })
+	return nil
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestLabelRenames(t *testing.T) {
+	_, collector := newMockRegistry(t, mockStorageBoxResponse())
+	if err := collector.SetLabelRenames(map[string]string{"id": "box_id", "location": "dc"}); err != nil {
+		t.Fatalf("SetLabelRenames() unexpected error = %v", err)
+	}
+	// A pedantic registry checks emitted metrics against their descriptors
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(collector)
+
+	if got := labeledGaugeValue(t, reg, "storagebox_disk_quota_bytes", map[string]string{"box_id": "12345", "dc": "fsn1"}); got == -1 {
+		t.Error("expected storagebox_disk_quota_bytes with the renamed box_id and dc labels")
+	}
+	if got := labeledGaugeValue(t, reg, "storagebox_disk_quota_bytes", map[string]string{"id": "12345"}); got != -1 {
+		t.Error("expected no id label after renaming it")
+	}
+	if got := labeledGaugeValue(t, reg, "storagebox_status", map[string]string{"box_id": "12345", "status": "active"}); got != 1 {
+		t.Errorf("expected labels that are not renamed to remain, got %v", got)
+	}
+
+	for _, metric := range collector.Schema() {
+		for _, label := range metric.Labels {
+			if label == "id" {
+				t.Errorf("expected the schema to show renamed labels, %s has id", metric.Name)
+			}
+		}
+	}
+
+	// The incremental endpoint applies the same renames
+	since := prometheus.NewPedanticRegistry()
+	since.MustRegister(collector.Since(context.Background(), time.Time{}))
+	if got := labeledGaugeValue(t, since, "storagebox_disk_usage_bytes", map[string]string{"box_id": "12345"}); got == -1 {
+		t.Error("expected the since collector to rename labels")
+	}
+}
+
+func TestLabelRenamesInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		renames map[string]string
+	}{
+		{name: "unknown label", renames: map[string]string{"box": "box_id"}},
+		{name: "invalid target", renames: map[string]string{"id": "box-id"}},
+		{name: "reserved target", renames: map[string]string{"id": "__id"}},
+		{name: "collides with existing label", renames: map[string]string{"id": "name"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, collector := newMockRegistry(t, mockStorageBoxResponse())
+			if err := collector.SetLabelRenames(tt.renames); err == nil {
+				t.Errorf("SetLabelRenames(%v) expected error", tt.renames)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricSchema describes one metric family by name and label keys
+type MetricSchema struct {
+	Name   string   `json:"name"`
+	Labels []string `json:"labels"`
+}
+
+// Schema returns the metrics the collector can expose, sorted by name, with
+// their sorted label keys. It is built from the descriptors, so no API call is
+// made and metrics only emitted under some conditions are included as well.
+// Excluded metrics are left out.
+func (c *StorageBoxCollector) Schema() []MetricSchema {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(descs)
+		close(descs)
+	}()
+
+	var schema []MetricSchema
+	for desc := range descs {
+		info, _ := c.descs.info(desc)
+		if c.excluded[info.name] {
+			continue
+		}
+		labels := append([]string{}, info.labels...)
+		sort.Strings(labels)
+		schema = append(schema, MetricSchema{Name: info.name, Labels: labels})
+	}
+	sort.Slice(schema, func(i, j int) bool { return schema[i].Name < schema[j].Name })
+	return schema
+}
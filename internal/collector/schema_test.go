@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/hetzner"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSchema(t *testing.T) {
+	collector := NewStorageBoxCollector(hetzner.NewClient("test-token"), 0, 0, 0, BuildInfo{})
+	if err := collector.SetExcludedMetrics([]string{"storagebox_access_zfs_enabled"}); err != nil {
+		t.Fatalf("SetExcludedMetrics() unexpected error: %v", err)
+	}
+
+	schema := collector.Schema()
+	labels := make(map[string][]string, len(schema))
+	for _, metric := range schema {
+		labels[metric.Name] = metric.Labels
+	}
+
+	if !sort.SliceIsSorted(schema, func(i, j int) bool { return schema[i].Name < schema[j].Name }) {
+		t.Error("expected the schema to be sorted by metric name")
+	}
+	if got, want := labels["storagebox_disk_usage_bytes"], []string{"id", "location", "name", "server"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected storagebox_disk_usage_bytes labels %v, got %v", want, got)
+	}
+	if got, ok := labels["storagebox_exporter_up"]; !ok || len(got) != 0 {
+		t.Errorf("expected storagebox_exporter_up without labels, got %v (present: %v)", got, ok)
+	}
+	// Conditionally emitted metrics are part of the schema
+	if _, ok := labels["storagebox_exporter_token_source"]; !ok {
+		t.Error("expected storagebox_exporter_token_source in the schema")
+	}
+	if _, ok := labels["storagebox_access_zfs_enabled"]; ok {
+		t.Error("expected excluded metrics to be left out")
+	}
+}
+
+func TestDescribedDescsAreInTable(t *testing.T) {
+	collector := NewStorageBoxCollector(hetzner.NewClient("test-token"), 0, 0, 0, BuildInfo{})
+	collector.SetBooleanStyle(true)
+	collector.SetCreatedLabel(true)
+	if err := collector.SetExportedLabels([]string{"team"}); err != nil {
+		t.Fatalf("SetExportedLabels() unexpected error: %v", err)
+	}
+	if err := collector.SetLabelRenames(map[string]string{"id": "box_id"}); err != nil {
+		t.Fatalf("SetLabelRenames() unexpected error: %v", err)
+	}
+
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		collector.Describe(descs)
+		close(descs)
+	}()
+	for desc := range descs {
+		info, ok := collector.descs.info(desc)
+		if !ok || info.name == "" || info.help == "" {
+			t.Errorf("expected %s in the descriptor table, got %+v (present: %v)", desc, info, ok)
+		}
+		if info.name == "storagebox_info" {
+			want := []string{"box_id", "name", "username", "server", "location", "storage_type", "system", "created", "label_team"}
+			if !reflect.DeepEqual(info.labels, want) {
+				t.Errorf("expected storagebox_info labels %v, got %v", want, info.labels)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ScrapeOptions are the options of a single scrape request
+type ScrapeOptions struct {
+	// Refresh fetches fresh data from the API, bypassing the cache and the
+	// minimum scrape interval. The fresh data is cached for later scrapes;
+	// the cache is otherwise left intact.
+	Refresh bool
+	// Context is the context of the scrape request. API calls made for the
+	// scrape are abandoned once it is cancelled, e.g. because Prometheus
+	// gave up on the scrape. nil means context.Background().
+	Context context.Context
+	// NoPollTimestamps leaves the box metrics without the time of the last
+	// poll, even with poll timestamps enabled, e.g. for a metrics file read
+	// by a collector that rejects explicit timestamps
+	NoPollTimestamps bool
+}
+
+// context returns the context of the scrape request
+func (o ScrapeOptions) context() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+// scrapeCollector collects the metrics of its parent with the options of a
+// single scrape request
+type scrapeCollector struct {
+	parent *StorageBoxCollector
+	opts   ScrapeOptions
+}
+
+// Scrape returns a collector for a single scrape request, collecting the
+// metrics of c with the given options. It is meant to be registered on a
+// registry per request instead of c itself.
+func (c *StorageBoxCollector) Scrape(opts ScrapeOptions) prometheus.Collector {
+	return &scrapeCollector{parent: c, opts: opts}
+}
+
+// Describe implements prometheus.Collector
+func (s *scrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	s.parent.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (s *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	s.parent.collect(ch, s.opts)
+}
@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSinceCollector(t *testing.T) {
+	tests := []struct {
+		name    string
+		since   time.Time
+		wantIDs []string
+	}{
+		{name: "zero time includes all boxes", since: time.Time{}, wantIDs: []string{"12345", "12346"}},
+		{name: "between creation times", since: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), wantIDs: []string{"12346"}},
+		{name: "equal to creation time is excluded", since: time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC), wantIDs: nil},
+		{name: "after all creation times", since: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), wantIDs: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, parent := newMockRegistry(t, mockStorageBoxResponse())
+			reg := prometheus.NewRegistry()
+			if err := reg.Register(parent.Since(context.Background(), tt.since)); err != nil {
+				t.Fatalf("failed to register since collector: %v", err)
+			}
+
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("failed to gather metrics: %v", err)
+			}
+
+			var gotIDs []string
+			for _, mf := range families {
+				if mf.GetName() == "storagebox_exporter_up" || mf.GetName() == "storagebox_type_box_count" {
+					t.Errorf("unexpected non per-box metric %s", mf.GetName())
+				}
+				if mf.GetName() != "storagebox_disk_usage_bytes" {
+					continue
+				}
+				for _, m := range mf.GetMetric() {
+					for _, lp := range m.GetLabel() {
+						if lp.GetName() == "id" {
+							gotIDs = append(gotIDs, lp.GetValue())
+						}
+					}
+				}
+			}
+
+			if len(gotIDs) != len(tt.wantIDs) {
+				t.Fatalf("expected boxes %v, got %v", tt.wantIDs, gotIDs)
+			}
+			for i := range gotIDs {
+				if gotIDs[i] != tt.wantIDs[i] {
+					t.Errorf("expected boxes %v, got %v", tt.wantIDs, gotIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestSinceCollectorAPIError(t *testing.T) {
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer server.Close()
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{}).Since(context.Background(), time.Time{})); err != nil {
+		t.Fatalf("failed to register since collector: %v", err)
+	}
+	if _, err := reg.Gather(); err == nil {
+		t.Error("expected gather to fail when the API is unavailable")
+	}
+}
+
+func TestSinceCollectorLeavesStateUntouched(t *testing.T) {
+	var calls atomic.Int32
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// Usage halves after the first call
+		size := 1000
+		if calls.Add(1) > 1 {
+			size = 500
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"storage_boxes": [{"id": 1, "name": "box", "status": "active", "created": "2024-01-01T00:00:00Z", "stats": {"size": %d}}]}`, size)
+	})
+	defer server.Close()
+
+	parent := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(parent)
+	sinceReg := prometheus.NewRegistry()
+	sinceReg.MustRegister(parent.Since(context.Background(), time.Time{}))
+
+	// Only the first since scrape calls the API, as nothing was fetched yet
+	for range 2 {
+		if _, err := sinceReg.Gather(); err != nil {
+			t.Fatalf("failed to gather since metrics: %v", err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected since scrapes to reuse the fetched boxes, got %d API calls", got)
+	}
+
+	// The usage seen by the since scrapes must not count as previous usage
+	labels := map[string]string{"id": "1"}
+	if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_drop_ratio", labels); got != 0 {
+		t.Errorf("expected no usage drop on the first scrape, got %v", got)
+	}
+	if got := labeledGaugeValue(t, sinceReg, "storagebox_disk_usage_bytes", labels); got != 500 {
+		t.Errorf("expected since to serve the boxes of the last scrape, got usage %v", got)
+	}
+	if got := counterValue(t, reg, "storagebox_exporter_scrapes_total"); got != 2 {
+		t.Errorf("expected since scrapes not to count as scrapes, got %v", got)
+	}
+}
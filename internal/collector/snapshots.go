@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/hetzner"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultDetailConcurrency bounds how many per-box API calls run at once
+// unless changed with SetDetailConcurrency
+const defaultDetailConcurrency = 4
+
+// snapshotSummary condenses the snapshots of a storage box. It is cached as
+// JSON along with the storage boxes.
+type snapshotSummary struct {
+	Count  int       `json:"count"`
+	Oldest time.Time `json:"oldest"` // Zero without snapshots
+}
+
+// SetSnapshotMetrics enables storagebox_snapshots_count and
+// storagebox_snapshot_oldest_timestamp. They cost one extra API call per
+// storage box whenever storage boxes are fetched from the API; cache hits and
+// scrapes in poll mode serve the snapshots fetched along with the boxes.
+func (c *StorageBoxCollector) SetSnapshotMetrics(enabled bool) {
+	c.snapshotMetrics = enabled
+}
+
+// SetDetailConcurrency sets how many per-box API calls, such as the snapshot
+// listings, run at once. It must be at least 1.
+func (c *StorageBoxCollector) SetDetailConcurrency(n int) {
+	c.detailLimit = n
+}
+
+// fetchSnapshots lists the snapshots of every box, at most
+// detailLimit at a time, and records their summaries for the next
+// scrapes. The listings get a deadline of their own, abandoned once parent is
+// cancelled. Boxes whose snapshots cannot be listed are left out without
+// failing the scrape; a 404 means the box was deleted after it was listed.
+func (c *StorageBoxCollector) fetchSnapshots(parent context.Context, boxes []hetzner.StorageBox) {
+	if !c.snapshotMetrics {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(parent, c.apiTimeout)
+	defer cancel()
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, c.detailLimit)
+	)
+	summaries := make(map[int64]snapshotSummary, len(boxes))
+	for _, box := range boxes {
+		if c.skipInactive.Load() && box.Status != "active" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if summary, ok := c.fetchSnapshotSummary(ctx, parent, &box); ok {
+				mu.Lock()
+				summaries[box.ID] = summary
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.stateMu.Lock()
+	c.snapshots = summaries
+	c.stateMu.Unlock()
+}
+
+// fetchSnapshotSummary lists the snapshots of box and condenses them. Failed
+// listings are counted like failed listings of storage boxes, except for a
+// 404 and for listings abandoned because parent was cancelled.
+func (c *StorageBoxCollector) fetchSnapshotSummary(ctx, parent context.Context, box *hetzner.StorageBox) (snapshotSummary, bool) {
+	snapshots, err := c.client.ListSnapshots(ctx, box.ID)
+	var apiErr *hetzner.APIError
+	switch {
+	case errors.Is(err, context.Canceled) && parent.Err() != nil:
+		c.scrapeCancelled.Inc()
+		return snapshotSummary{}, false
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+		slog.Debug("Storage box gone while listing its snapshots", "id", box.ID, "name", box.Name)
+		return snapshotSummary{}, false
+	case err != nil:
+		c.apiOutcomes.record(false)
+		c.handleError(err, "snapshots")
+		return snapshotSummary{}, false
+	}
+	c.apiOutcomes.record(true)
+
+	summary := snapshotSummary{Count: len(snapshots)}
+	for _, snapshot := range snapshots {
+		if summary.Oldest.IsZero() || snapshot.Created.Before(summary.Oldest) {
+			summary.Oldest = snapshot.Created
+		}
+	}
+	return summary, true
+}
+
+// collectSnapshots emits the snapshot metrics of a box whose snapshots are known
+func (c *StorageBoxCollector) collectSnapshots(ch chan<- prometheus.Metric, box *hetzner.StorageBox, id, name string) {
+	if !c.snapshotMetrics {
+		return
+	}
+	c.stateMu.Lock()
+	summary, ok := c.snapshots[box.ID]
+	c.stateMu.Unlock()
+	if !ok {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.snapshotCount, prometheus.GaugeValue, float64(summary.Count), id, name)
+	if !summary.Oldest.IsZero() {
+		ch <- prometheus.MustNewConstMetric(c.snapshotOldest, prometheus.GaugeValue, float64(summary.Oldest.Unix()), id, name)
+	}
+}
@@ -2,15 +2,24 @@ package collector
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/crstian19/prometheus-storagebox-exporter/internal/cache"
 	"github.com/crstian19/prometheus-storagebox-exporter/internal/hetzner"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // BuildInfo carries version metadata injected at build time, exposed as the
@@ -23,230 +32,1034 @@ type BuildInfo struct {
 
 // StorageBoxCollector implements the prometheus.Collector interface
 type StorageBoxCollector struct {
-	client       *hetzner.Client
-	cache        *cache.MetricsCache
-	cacheEnabled bool
+	client           *hetzner.Client
+	cache            cache.Cache
+	cacheEnabled     atomic.Bool
+	apiOutcomes      *outcomeWindow
+	readiness        *readinessPolicy
+	usageCounter     bool
+	sizeDivisor      float64 // Bytes per emitted size unit
+	sizeRound        bool
+	createdLabel     bool
+	exportedLabels   []string // Box label keys added to storagebox_info
+	exportedNames    []string // Prometheus label names of exportedLabels
+	maxExported      int      // Cap on len(exportedLabels), 0 for none
+	stateset         bool     // Boolean metrics as statesets instead of 1/0 gauges
+	externalAlias    bool
+	protocolMetric   bool // Also expose storagebox_access with a protocol label
+	skipInactive     atomic.Bool
+	nameConvention   *regexp.Regexp
+	requiredLabels   []string
+	apiTimeout       time.Duration
+	paused           atomic.Bool
+	lastRetries      atomic.Int64
+	lastPayload      atomic.Int64
+	lastPartial      atomic.Bool
+	lastTruncated    atomic.Bool
+	lastSource       atomic.Value // string, where the last scrape got its storage boxes from
+	summaryLog       bool
+	snapshotMetrics  bool
+	detailLimit      int         // Per-box API calls made at once
+	apiDeprecated    atomic.Bool // From the last API call, kept on cached scrapes
+	pollInterval     time.Duration
+	excluded         map[string]bool // Metric names suppressed via SetExcludedMetrics
+	renamer          *labelRenamer   // Label renames set via SetLabelRenames, nil for none
+	tokenSource      string
+	lastPoll         atomic.Int64 // Unix nanoseconds of the last completed poll
+	lastPollData     atomic.Int64 // Unix nanoseconds of the last successful poll
+	lastSuccess      atomic.Int64 // Unix nanoseconds of the last scrape without fetch error
+	lastScrapeStatus atomic.Value // string, "success" or "error"
+	pollTimestamps   bool
+	minInterval      time.Duration
+	failureGrace     time.Duration
+
+	// Per-box state retained across scrapes
+	stateMu         sync.Mutex
+	peakUsage       map[int64]int64
+	lastUsage       map[int64]int64
+	lastDrop        map[int64]float64
+	lastType        map[int64]string
+	typeChangeCount map[int64]int
+	lastBoxes       []hetzner.StorageBox
+	lastFetch       time.Time // Time of the last complete API fetch
+	failingSince    time.Time // Start of the current streak of failed fetches
+	lastGoroutines  int
+	lastPollErr     error
+	sharedNames     map[string]bool           // Names shared by several boxes at the last check
+	sharedHosts     map[string]bool           // Servers shared by several boxes at the last check
+	snapshots       map[int64]snapshotSummary // From the last API fetch or cache hit with snapshot metrics
+
+	// Name, help and labels of the descriptors below
+	descs *descTable
 
 	// Core storage metrics
 	diskQuota          *prometheus.Desc
 	diskUsage          *prometheus.Desc
 	diskUsageData      *prometheus.Desc
 	diskUsageSnapshots *prometheus.Desc
+	diskUsageRatio     *prometheus.Desc
+	diskUsagePeak      *prometheus.Desc
+	snapshotRatio      *prometheus.Desc
+	snapshotDataRatio  *prometheus.Desc
+	usageDropRatio     *prometheus.Desc
 
 	// Info and status metrics
 	info              *prometheus.Desc
 	status            *prometheus.Desc
+	typeChanges       *prometheus.Desc
+	nameViolation     *prometheus.Desc
+	missingLabel      *prometheus.Desc
 	accessSSH         *prometheus.Desc
 	accessSamba       *prometheus.Desc
 	accessWebDAV      *prometheus.Desc
 	accessZFS         *prometheus.Desc
+	access            *prometheus.Desc
 	reachableExternal *prometheus.Desc
+	accessExternal    *prometheus.Desc
+	externalMismatch  *prometheus.Desc
 	snapshotPlan      *prometheus.Desc
+	snapshotPlanSet   *prometheus.Desc
+	snapshotMax       *prometheus.Desc
+	snapshotHour      *prometheus.Desc
+	snapshotWeekday   *prometheus.Desc
+	snapshotCount     *prometheus.Desc
+	snapshotOldest    *prometheus.Desc
 	protectionDelete  *prometheus.Desc
 	createdTimestamp  *prometheus.Desc
+	daysSinceCreated  *prometheus.Desc
+
+	// Fleet summary metrics
+	typeBoxCount   *prometheus.Desc
+	statusBoxCount *prometheus.Desc
+	boxesTotal     *prometheus.Desc
+	quotaMin       *prometheus.Desc
+	quotaMax       *prometheus.Desc
+	quotaAvg       *prometheus.Desc
+	accountQuota   *prometheus.Desc
+	accountUsage   *prometheus.Desc
+	usageRatioDist *prometheus.Desc
+	sshCount       *prometheus.Desc
+	sambaCount     *prometheus.Desc
+	webdavCount    *prometheus.Desc
+	zfsCount       *prometheus.Desc
 
 	// Exporter metrics
-	up             *prometheus.Desc
-	buildInfo      *prometheus.Desc
-	buildInfoData  BuildInfo
-	scrapeDuration *prometheus.Desc
-	scrapeErrors   prometheus.Counter
-	cacheHits      prometheus.Counter
-	cacheMisses    prometheus.Counter
+	up               *prometheus.Desc
+	storageBoxUp     *prometheus.Desc
+	buildInfo        *prometheus.Desc
+	buildInfoData    BuildInfo
+	scrapeDuration   *prometheus.Desc
+	successRatio     *prometheus.Desc
+	pausedDesc       *prometheus.Desc
+	retriesDesc      *prometheus.Desc
+	readinessDesc    *prometheus.Desc
+	goroutineDelta   *prometheus.Desc
+	payloadBytes     *prometheus.Desc
+	pollIntervalDesc *prometheus.Desc
+	lastPollDesc     *prometheus.Desc
+	lastSuccessDesc  *prometheus.Desc
+	heartbeat        *prometheus.Desc
+	partialDesc      *prometheus.Desc
+	truncatedDesc    *prometheus.Desc
+	deprecatedDesc   *prometheus.Desc
+	tokenGeneration  *prometheus.Desc
+	tokenSourceDesc  *prometheus.Desc
+	cacheBackend     *prometheus.Desc
+	redisUp          *prometheus.Desc
+	redisErrors      *prometheus.Desc
+	apiEndpoint      *prometheus.Desc
+	scrapeErrors     prometheus.Counter
+	scrapesTotal     prometheus.Counter
+	cacheHits        prometheus.Counter
+	cacheMisses      prometheus.Counter
+	duplicateNames   prometheus.Counter
+	sharedServers    prometheus.Counter
 
 	// Error type metrics
-	authErrors      prometheus.Counter
-	rateLimitErrors prometheus.Counter
-	serverErrors    prometheus.Counter
-	clientErrors    prometheus.Counter
-	networkErrors   prometheus.Counter
+	authErrors          prometheus.Counter
+	rateLimitErrors     prometheus.Counter
+	serverErrors        prometheus.Counter
+	clientErrors        prometheus.Counter
+	networkErrors       prometheus.Counter
+	unexpectedResponses prometheus.Counter
+	scrapeCancelled     prometheus.Counter
+	tokenReloadFailures prometheus.Counter
+}
+
+// errNoLastKnownData is returned while paused if no data has been fetched yet
+var errNoLastKnownData = errors.New("API calls are paused and no last-known data is available")
+
+// defaultAPITimeout bounds a single listing of storage boxes, including
+// pagination and retries, unless configured otherwise
+const defaultAPITimeout = 30 * time.Second
+
+// defaultSuccessWindow is the number of recent API calls considered by
+// storagebox_exporter_api_success_ratio unless configured otherwise
+const defaultSuccessWindow = 10
+
+// usageRatioOpts configures storagebox_usage_ratio_distribution
+var usageRatioOpts = prometheus.HistogramOpts{
+	Name: "storagebox_usage_ratio_distribution",
+	Help: "Distribution of the per-box usage ratios (usage / quota, 0-1) of the current storage boxes",
+	// Classic buckets for scrapers without native histogram support
+	Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
+	// Native histogram with schema 3: bucket boundaries grow by a factor of
+	// 2^(1/8), about 9%. Resolution is reduced should more than 160 buckets
+	// be populated.
+	NativeHistogramBucketFactor:    1.1,
+	NativeHistogramMaxBucketNumber: 160,
+}
+
+// histogramMetric is a histogram built for a single scrape, exposed under a
+// descriptor of the collector
+type histogramMetric struct {
+	desc      *prometheus.Desc
+	histogram *dto.Histogram
+}
+
+// Desc implements prometheus.Metric
+func (m histogramMetric) Desc() *prometheus.Desc {
+	return m.desc
+}
+
+// Write implements prometheus.Metric
+func (m histogramMetric) Write(out *dto.Metric) error {
+	out.Histogram = m.histogram
+	return nil
 }
 
 // NewStorageBoxCollector creates a new StorageBoxCollector
 func NewStorageBoxCollector(client *hetzner.Client, cacheTTL time.Duration, cacheMaxSize int64, cacheCleanupInterval time.Duration, buildInfo BuildInfo) *StorageBoxCollector {
-	cacheEnabled := cacheTTL > 0
-	return &StorageBoxCollector{
-		client:        client,
-		cache:         cache.NewMetricsCache(cacheTTL, cacheMaxSize, cacheCleanupInterval),
-		cacheEnabled:  cacheEnabled,
-		buildInfoData: buildInfo,
+	descs := newDescTable()
+	c := &StorageBoxCollector{
+		descs:           descs,
+		client:          client,
+		cache:           cache.NewMetricsCache(cacheTTL, cacheMaxSize, cacheCleanupInterval),
+		apiOutcomes:     newOutcomeWindow(defaultSuccessWindow),
+		apiTimeout:      defaultAPITimeout,
+		detailLimit:     defaultDetailConcurrency,
+		sizeDivisor:     1,
+		readiness:       newReadinessPolicy(),
+		peakUsage:       make(map[int64]int64),
+		lastUsage:       make(map[int64]int64),
+		lastDrop:        make(map[int64]float64),
+		lastType:        make(map[int64]string),
+		typeChangeCount: make(map[int64]int),
+		buildInfoData:   buildInfo,
 
 		// Core storage metrics
-		diskQuota: prometheus.NewDesc(
+		diskQuota: descs.desc(
 			"storagebox_disk_quota_bytes",
 			"Total allocated diskspace in bytes",
 			[]string{"id", "name", "server", "location"},
-			nil,
 		),
-		diskUsage: prometheus.NewDesc(
+		diskUsage: descs.desc(
 			"storagebox_disk_usage_bytes",
 			"Total used diskspace in bytes",
 			[]string{"id", "name", "server", "location"},
-			nil,
 		),
-		diskUsageData: prometheus.NewDesc(
+		diskUsageData: descs.desc(
 			"storagebox_disk_usage_data_bytes",
 			"Diskspace used by files in bytes",
 			[]string{"id", "name", "server", "location"},
-			nil,
 		),
-		diskUsageSnapshots: prometheus.NewDesc(
+		diskUsageSnapshots: descs.desc(
 			"storagebox_disk_usage_snapshots_bytes",
 			"Diskspace used by snapshots in bytes",
 			[]string{"id", "name", "server", "location"},
-			nil,
+		),
+		diskUsageRatio: descs.desc(
+			"storagebox_disk_usage_ratio",
+			"Used diskspace relative to the quota (0-1), 0 for boxes without a known quota",
+			[]string{"id", "name", "server", "location"},
+		),
+		diskUsagePeak: descs.desc(
+			"storagebox_disk_usage_bytes_total",
+			"Synthetic monotonic view of used diskspace: the peak usage in bytes observed since the exporter started",
+			[]string{"id", "name", "server", "location"},
+		),
+		usageDropRatio: descs.desc(
+			"storagebox_disk_usage_drop_ratio",
+			"Fractional decrease of used diskspace between the last two API fetches (0-1), 0 when usage grew or stayed the same; a possible data loss signal",
+			[]string{"id", "name"},
+		),
+		snapshotDataRatio: descs.desc(
+			"storagebox_snapshot_to_data_ratio",
+			"Diskspace used by snapshots relative to diskspace used by files, 0 for boxes without file data",
+			[]string{"id", "name"},
+		),
+		snapshotRatio: descs.desc(
+			"storagebox_snapshot_usage_ratio",
+			"Share of used diskspace taken by snapshots (0-1)",
+			[]string{"id", "name"},
 		),
 
 		// Info and status metrics
-		info: prometheus.NewDesc(
-			"storagebox_info",
-			"Storage box information",
-			[]string{"id", "name", "username", "server", "location", "storage_type", "system"},
-			nil,
-		),
-		status: prometheus.NewDesc(
+		info: newInfoDesc(descs, false, nil),
+		status: descs.desc(
 			"storagebox_status",
 			"Storage box status (always 1, status in label: active, initializing, locked)",
 			[]string{"id", "name", "status"},
-			nil,
 		),
-		accessSSH: prometheus.NewDesc(
-			"storagebox_access_ssh_enabled",
-			"SSH access enabled (1=enabled, 0=disabled)",
+		nameViolation: descs.desc(
+			"storagebox_name_convention_violation",
+			"Whether the storage box name violates the configured naming convention (1=violation, 0=conforming)",
 			[]string{"id", "name"},
-			nil,
 		),
-		accessSamba: prometheus.NewDesc(
-			"storagebox_access_samba_enabled",
-			"Samba/CIFS access enabled (1=enabled, 0=disabled)",
+		missingLabel: descs.desc(
+			"storagebox_missing_required_label",
+			"Whether the storage box lacks a required label key (1=missing, 0=present)",
+			[]string{"id", "name", "label"},
+		),
+		typeChanges: descs.desc(
+			"storagebox_type_changes_total",
+			"Number of storage box type changes (upgrades or downgrades) observed since exporter start",
 			[]string{"id", "name"},
-			nil,
 		),
-		accessWebDAV: prometheus.NewDesc(
-			"storagebox_access_webdav_enabled",
-			"WebDAV access enabled (1=enabled, 0=disabled)",
+		externalMismatch: descs.desc(
+			"storagebox_access_external_mismatch",
+			"Access protocols enabled while the storage box is not reachable externally (1=mismatch, 0=consistent)",
 			[]string{"id", "name"},
-			nil,
 		),
-		accessZFS: prometheus.NewDesc(
-			"storagebox_access_zfs_enabled",
-			"ZFS access enabled (1=enabled, 0=disabled)",
+		snapshotPlanSet: descs.desc(
+			"storagebox_snapshot_plan_configured",
+			"Whether a snapshot plan exists for the storage box, enabled or not (1=configured, 0=no plan)",
 			[]string{"id", "name"},
-			nil,
 		),
-		reachableExternal: prometheus.NewDesc(
-			"storagebox_reachable_externally",
-			"Storage box reachable from external networks (1=reachable, 0=not reachable)",
+		snapshotMax: descs.desc(
+			"storagebox_snapshot_plan_max_snapshots",
+			"Number of snapshots the snapshot plan keeps before deleting the oldest, only exposed for boxes with a snapshot plan",
 			[]string{"id", "name"},
-			nil,
 		),
-		snapshotPlan: prometheus.NewDesc(
-			"storagebox_snapshot_plan_enabled",
-			"Automatic snapshot plan configured (1=enabled, 0=disabled)",
+		snapshotHour: descs.desc(
+			"storagebox_snapshot_plan_hour",
+			"Hour of the day (0-23) the snapshot plan takes snapshots at, only exposed for boxes with a snapshot plan",
 			[]string{"id", "name"},
-			nil,
 		),
-		protectionDelete: prometheus.NewDesc(
-			"storagebox_protection_delete",
-			"Delete protection status (1=protected, 0=unprotected)",
+		snapshotWeekday: descs.desc(
+			"storagebox_snapshot_plan_day_of_week",
+			"Day of the week (1=Monday to 7=Sunday) the snapshot plan takes snapshots on, only exposed for weekly snapshot plans",
+			[]string{"id", "name"},
+		),
+		snapshotCount: descs.desc(
+			"storagebox_snapshots_count",
+			"Number of snapshots of the storage box, only exposed with --snapshot-metrics",
+			[]string{"id", "name"},
+		),
+		snapshotOldest: descs.desc(
+			"storagebox_snapshot_oldest_timestamp",
+			"Unix timestamp of the creation of the oldest snapshot of the storage box, only exposed with --snapshot-metrics for boxes with snapshots",
 			[]string{"id", "name"},
-			nil,
 		),
-		createdTimestamp: prometheus.NewDesc(
+		createdTimestamp: descs.desc(
 			"storagebox_created_timestamp",
 			"Unix timestamp of storage box creation",
 			[]string{"id", "name"},
+		),
+		daysSinceCreated: descs.desc(
+			"storagebox_days_since_created",
+			"Number of full days since the storage box was created",
+			[]string{"id", "name"},
+		),
+
+		// Fleet summary metrics
+		typeBoxCount: descs.desc(
+			"storagebox_type_box_count",
+			"Number of storage boxes of each storage box type",
+			[]string{"type"},
+		),
+		statusBoxCount: descs.desc(
+			"storagebox_status_box_count",
+			"Number of storage boxes in each status, including boxes skipped by --skip-inactive",
+			[]string{"status"},
+		),
+		quotaMin: descs.desc(
+			"storagebox_disk_quota_bytes_min",
+			"Smallest storage box quota across the account in bytes",
+			nil,
+		),
+		quotaMax: descs.desc(
+			"storagebox_disk_quota_bytes_max",
+			"Largest storage box quota across the account in bytes",
+			nil,
+		),
+		quotaAvg: descs.desc(
+			"storagebox_disk_quota_bytes_avg",
+			"Average storage box quota across the account in bytes",
+			nil,
+		),
+		accountQuota: descs.desc(
+			"storagebox_account_total_quota_bytes",
+			"Sum of the quotas of all storage boxes in the account in bytes",
+			nil,
+		),
+		accountUsage: descs.desc(
+			"storagebox_account_total_usage_bytes",
+			"Sum of the disk usage of all storage boxes in the account in bytes",
+			nil,
+		),
+		sshCount: descs.desc(
+			"storagebox_access_ssh_enabled_count",
+			"Number of storage boxes with SSH access enabled",
+			nil,
+		),
+		sambaCount: descs.desc(
+			"storagebox_access_samba_enabled_count",
+			"Number of storage boxes with Samba access enabled",
+			nil,
+		),
+		webdavCount: descs.desc(
+			"storagebox_access_webdav_enabled_count",
+			"Number of storage boxes with WebDAV access enabled",
+			nil,
+		),
+		zfsCount: descs.desc(
+			"storagebox_access_zfs_enabled_count",
+			"Number of storage boxes with ZFS access enabled",
+			nil,
+		),
+		usageRatioDist: descs.desc(
+			usageRatioOpts.Name,
+			usageRatioOpts.Help,
+			nil,
+		),
+		boxesTotal: descs.desc(
+			"storagebox_exporter_boxes_total",
+			"Number of storage boxes returned by the last successful fetch",
 			nil,
 		),
 
 		// Exporter metrics
-		up: prometheus.NewDesc(
+		up: descs.desc(
 			"storagebox_exporter_up",
 			"Whether the last scrape of the Hetzner API succeeded (1=healthy, 0=unhealthy)",
 			nil,
+		),
+		storageBoxUp: descs.desc(
+			"storagebox_up",
+			"Whether storage boxes were fetched successfully, even if the account has none (1=success, 0=failure)",
 			nil,
 		),
-		buildInfo: prometheus.NewDesc(
+		buildInfo: descs.desc(
 			"storagebox_exporter_build_info",
 			"Build information of the exporter (value always 1)",
 			[]string{"version", "revision", "goversion", "build_date"},
+		),
+		heartbeat: descs.desc(
+			"storagebox_exporter_heartbeat",
+			"Unix timestamp of the last scrape, set on every scrape regardless of the API outcome",
 			nil,
 		),
-		scrapeDuration: prometheus.NewDesc(
+		scrapeDuration: descs.desc(
 			"storagebox_exporter_scrape_duration_seconds",
 			"Duration of the scrape in seconds",
 			nil,
+		),
+		pausedDesc: descs.desc(
+			"storagebox_exporter_paused",
+			"Whether API calls are paused and last-known data is served (1=paused, 0=active)",
+			nil,
+		),
+		retriesDesc: descs.desc(
+			"storagebox_exporter_last_scrape_retries",
+			"Number of Hetzner API requests retried during the last scrape",
+			nil,
+		),
+		readinessDesc: descs.desc(
+			"storagebox_exporter_readiness",
+			"Readiness as reported by /ready: 0 after sustained authentication failures or prolonged API failure, 1 otherwise",
+			nil,
+		),
+		payloadBytes: descs.desc(
+			"storagebox_exporter_api_payload_bytes",
+			"Size in bytes of the API response bodies decoded during the last scrape, 0 when served without API calls",
+			nil,
+		),
+		pollIntervalDesc: descs.desc(
+			"storagebox_exporter_poll_interval_seconds",
+			"Configured interval between background polls of the Hetzner API, only exposed in poll mode",
+			nil,
+		),
+		lastPollDesc: descs.desc(
+			"storagebox_exporter_last_poll_timestamp_seconds",
+			"Unix timestamp of the last completed background poll, only exposed in poll mode once a poll has completed",
+			nil,
+		),
+		lastSuccessDesc: descs.desc(
+			"storagebox_exporter_last_scrape_success_timestamp_seconds",
+			"Unix timestamp of the last scrape that fetched storage boxes without error, only exposed once a scrape has succeeded",
+			nil,
+		),
+		partialDesc: descs.desc(
+			"storagebox_exporter_partial_scrape",
+			"Whether the last scrape served partial data because a page failed with --pagination-on-error=partial (1=partial, 0=complete)",
+			nil,
+		),
+		truncatedDesc: descs.desc(
+			"storagebox_exporter_pagination_truncated",
+			"Whether the last API fetch stopped paginating at --max-pages before the last page (1=truncated, 0=complete)",
+			nil,
+		),
+		deprecatedDesc: descs.desc(
+			"storagebox_exporter_api_deprecated",
+			"Whether the last API call saw a Deprecation, Sunset or Warning header (1=deprecation announced, 0=none)",
+			nil,
+		),
+		tokenGeneration: descs.desc(
+			"storagebox_exporter_token_generation",
+			"Number of times the API token has been replaced by a reload, 0 while the initial token is in use",
+			nil,
+		),
+		tokenSourceDesc: descs.desc(
+			"storagebox_exporter_token_source",
+			"How the API token was resolved at startup (value always 1)",
+			[]string{"source"},
+		),
+		apiEndpoint: descs.desc(
+			"storagebox_exporter_api_endpoint_info",
+			"Hetzner API base URL in use, without credentials (value always 1)",
+			[]string{"url"},
+		),
+		cacheBackend: descs.desc(
+			"storagebox_exporter_cache_backend_info",
+			"Cache storage backend in effect (value always 1)",
+			[]string{"backend"},
+		),
+		redisUp: descs.desc(
+			"storagebox_exporter_redis_up",
+			"Whether the last command sent to the Redis cache succeeded (1) or failed (0)",
+			nil,
+		),
+		redisErrors: descs.desc(
+			"storagebox_exporter_redis_errors_total",
+			"Total number of failed Redis cache commands; scrapes fall back to the API meanwhile",
+			nil,
+		),
+		goroutineDelta: descs.desc(
+			"storagebox_exporter_goroutines_delta",
+			"Change in the number of goroutines since the previous scrape, a sanity signal for goroutine leaks",
 			nil,
 		),
-		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+		successRatio: descs.desc(
+			"storagebox_exporter_api_success_ratio",
+			"Ratio of successful Hetzner API calls over the sliding window of recent calls",
+			nil,
+		),
+		scrapeErrors: descs.counter(prometheus.CounterOpts{
 			Name: "storagebox_exporter_scrape_errors_total",
 			Help: "Total number of scrape errors",
 		}),
-		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+		scrapesTotal: descs.counter(prometheus.CounterOpts{
+			Name: "storagebox_exporter_scrapes_total",
+			Help: "Total number of scrapes served, successful or not",
+		}),
+		cacheHits: descs.counter(prometheus.CounterOpts{
 			Name: "storagebox_exporter_cache_hits_total",
 			Help: "Total number of cache hits",
 		}),
-		cacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+		cacheMisses: descs.counter(prometheus.CounterOpts{
 			Name: "storagebox_exporter_cache_misses_total",
 			Help: "Total number of cache misses",
 		}),
+		sharedServers: descs.counter(prometheus.CounterOpts{
+			Name: "storagebox_exporter_shared_server_total",
+			Help: "Total number of times a server hostname became shared by more than one storage box",
+		}),
+		duplicateNames: descs.counter(prometheus.CounterOpts{
+			Name: "storagebox_exporter_duplicate_names_total",
+			Help: "Total number of times a storage box name became shared by more than one storage box",
+		}),
 
 		// Error type counters
-		authErrors: prometheus.NewCounter(prometheus.CounterOpts{
+		authErrors: descs.counter(prometheus.CounterOpts{
 			Name: "storagebox_exporter_auth_errors_total",
 			Help: "Total number of authentication/authorization errors (401, 403)",
 		}),
-		rateLimitErrors: prometheus.NewCounter(prometheus.CounterOpts{
+		rateLimitErrors: descs.counter(prometheus.CounterOpts{
 			Name: "storagebox_exporter_rate_limit_errors_total",
 			Help: "Total number of rate limit errors (429)",
 		}),
-		serverErrors: prometheus.NewCounter(prometheus.CounterOpts{
+		serverErrors: descs.counter(prometheus.CounterOpts{
 			Name: "storagebox_exporter_server_errors_total",
 			Help: "Total number of server errors (5xx)",
 		}),
-		clientErrors: prometheus.NewCounter(prometheus.CounterOpts{
+		clientErrors: descs.counter(prometheus.CounterOpts{
 			Name: "storagebox_exporter_client_errors_total",
 			Help: "Total number of client errors (400, 404)",
 		}),
-		networkErrors: prometheus.NewCounter(prometheus.CounterOpts{
+		networkErrors: descs.counter(prometheus.CounterOpts{
 			Name: "storagebox_exporter_network_errors_total",
 			Help: "Total number of network/connection errors",
 		}),
+		tokenReloadFailures: descs.counter(prometheus.CounterOpts{
+			Name: "storagebox_exporter_token_reload_failures_total",
+			Help: "Total number of token file reloads that failed, keeping the previous token",
+		}),
+		scrapeCancelled: descs.counter(prometheus.CounterOpts{
+			Name: "storagebox_exporter_scrape_cancelled_total",
+			Help: "Total number of API calls abandoned because the scrape request that made them was cancelled, not counted as errors",
+		}),
+		unexpectedResponses: descs.counter(prometheus.CounterOpts{
+			Name: "storagebox_exporter_unexpected_response_errors_total",
+			Help: "Total number of successful API responses with an unexpected shape, e.g. a missing storage_boxes key",
+		}),
+	}
+	c.cacheEnabled.Store(cacheTTL > 0)
+	c.setBoolDescs(false)
+
+	return c
+}
+
+// SetSuccessWindow sets how many recent API calls are considered when computing
+// storagebox_exporter_api_success_ratio. Previously recorded outcomes are discarded.
+func (c *StorageBoxCollector) SetSuccessWindow(size int) {
+	c.apiOutcomes = newOutcomeWindow(size)
+}
+
+// sizeUnits maps the supported size units to their size in bytes
+var sizeUnits = map[string]float64{
+	"bytes": 1,
+	"kib":   1 << 10,
+	"mib":   1 << 20,
+	"gib":   1 << 30,
+}
+
+// SetSizeUnit emits the per-box disk sizes in unit instead of bytes, rounded
+// to whole units if round is set. Unknown units keep bytes.
+func (c *StorageBoxCollector) SetSizeUnit(unit string, round bool) {
+	divisor, ok := sizeUnits[unit]
+	if !ok {
+		divisor = 1
+	}
+	c.sizeDivisor = divisor
+	c.sizeRound = round
+}
+
+// size converts a size in bytes to the configured size unit
+func (c *StorageBoxCollector) size(bytes int64) float64 {
+	value := float64(bytes) / c.sizeDivisor
+	if c.sizeRound {
+		value = math.Round(value)
+	}
+	return value
+}
+
+// SetUsageCounter enables storagebox_disk_usage_bytes_total, a counter that
+// tracks the peak usage per storage box and therefore never decreases
+func (c *StorageBoxCollector) SetUsageCounter(enabled bool) {
+	c.usageCounter = enabled
+}
+
+// SetCreatedLabel adds the creation time as an RFC 3339 created label to
+// storagebox_info. Must be called before the collector is registered.
+func (c *StorageBoxCollector) SetCreatedLabel(enabled bool) {
+	c.createdLabel = enabled
+	c.info = newInfoDesc(c.descs, enabled, c.exportedNames)
+}
+
+// invalidLabelChars matches characters not allowed in Prometheus label names
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// SetExportedLabels adds the given Hetzner box label keys to storagebox_info
+// as label_<key> labels, with invalid characters replaced by underscores, e.g.
+// cost-center becomes label_cost_center. Boxes without a key get an empty
+// value. Keys that end up with the same label name are rejected. Must be
+// called before the collector is registered. Keys beyond the limit set with
+// SetMaxLabelProjection are dropped with a warning.
+func (c *StorageBoxCollector) SetExportedLabels(keys []string) error {
+	if c.maxExported > 0 && len(keys) > c.maxExported {
+		slog.Warn("Dropping exported labels beyond the label projection limit",
+			"limit", c.maxExported,
+			"dropped", keys[c.maxExported:],
+		)
+		keys = keys[:c.maxExported]
+	}
+	names := make([]string, 0, len(keys))
+	seen := make(map[string]string, len(keys))
+	for _, key := range keys {
+		name := "label_" + invalidLabelChars.ReplaceAllString(key, "_")
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("labels %q and %q both map to %s", other, key, name)
+		}
+		seen[name] = key
+		names = append(names, name)
+	}
+	c.exportedLabels = keys
+	c.exportedNames = names
+	c.info = newInfoDesc(c.descs, c.createdLabel, names)
+	return nil
+}
+
+// SetMaxLabelProjection caps how many box label keys SetExportedLabels adds to
+// storagebox_info, so a misconfiguration cannot project dozens of
+// high-cardinality labels. 0 means no limit. Must be called before
+// SetExportedLabels.
+func (c *StorageBoxCollector) SetMaxLabelProjection(max int) {
+	c.maxExported = max
+}
+
+// newInfoDesc creates the storagebox_info descriptor, optionally with the
+// created label and labels exported from box labels
+func newInfoDesc(descs *descTable, createdLabel bool, exported []string) *prometheus.Desc {
+	labels := []string{"id", "name", "username", "server", "location", "storage_type", "system"}
+	if createdLabel {
+		labels = append(labels, "created")
+	}
+	labels = append(labels, exported...)
+	return descs.desc(
+		"storagebox_info",
+		"Storage box information",
+		labels,
+	)
+}
+
+// boolStates are the state label values of boolean metrics in stateset style
+var boolStates = [2]string{"enabled", "disabled"}
+
+// newBoolDesc creates the descriptor of a boolean metric. legend explains the
+// 1/0 values of the gauge style; the stateset style adds a state label instead.
+func (c *StorageBoxCollector) newBoolDesc(name, help, legend string, stateset bool, labels ...string) *prometheus.Desc {
+	if stateset {
+		return c.descs.desc(name, help+", one series per state (1=current state)", append(labels, "state"))
+	}
+	return c.descs.desc(name, help+" ("+legend+")", labels)
+}
+
+// setBoolDescs (re)creates the descriptors of the boolean access, protection
+// and snapshot metrics for the given style
+func (c *StorageBoxCollector) setBoolDescs(stateset bool) {
+	c.stateset = stateset
+	c.accessSSH = c.newBoolDesc("storagebox_access_ssh_enabled", "SSH access enabled", "1=enabled, 0=disabled", stateset, "id", "name")
+	c.accessSamba = c.newBoolDesc("storagebox_access_samba_enabled", "Samba/CIFS access enabled", "1=enabled, 0=disabled", stateset, "id", "name")
+	c.accessWebDAV = c.newBoolDesc("storagebox_access_webdav_enabled", "WebDAV access enabled", "1=enabled, 0=disabled", stateset, "id", "name")
+	c.accessZFS = c.newBoolDesc("storagebox_access_zfs_enabled", "ZFS access enabled", "1=enabled, 0=disabled", stateset, "id", "name")
+	c.access = c.newBoolDesc("storagebox_access", "Access protocol enabled, one series per protocol", "1=enabled, 0=disabled", stateset, "id", "name", "protocol")
+	c.reachableExternal = c.newBoolDesc("storagebox_reachable_externally", "Storage box reachable from external networks", "1=reachable, 0=not reachable", stateset, "id", "name")
+	c.accessExternal = c.newBoolDesc("storagebox_access_external_enabled", "Storage box reachable from external networks, alias of storagebox_reachable_externally", "1=enabled, 0=disabled", stateset, "id", "name")
+	c.snapshotPlan = c.newBoolDesc("storagebox_snapshot_plan_enabled", "Automatic snapshot plan configured", "1=enabled, 0=disabled", stateset, "id", "name")
+	c.protectionDelete = c.newBoolDesc("storagebox_protection_delete", "Delete protection status", "1=protected, 0=unprotected", stateset, "id", "name")
+}
+
+// SetBooleanStyle switches the boolean access, protection and snapshot metrics
+// between a 1/0 gauge (false) and a stateset with a state label of enabled or
+// disabled, exactly one of which is 1 (true)
+func (c *StorageBoxCollector) SetBooleanStyle(stateset bool) {
+	c.setBoolDescs(stateset)
+}
+
+// SetExternalAccessAlias additionally exposes storagebox_reachable_externally
+// as storagebox_access_external_enabled, consistent with the other
+// storagebox_access_* metrics, so dashboards can move to the new name before
+// the old one is dropped
+func (c *StorageBoxCollector) SetExternalAccessAlias(enabled bool) {
+	c.externalAlias = enabled
+}
+
+// SetAccessProtocolMetric additionally exposes the access settings as
+// storagebox_access with a protocol label, for queries across protocols. It
+// doubles the access series of every box, so it is off by default.
+func (c *StorageBoxCollector) SetAccessProtocolMetric(enabled bool) {
+	c.protocolMetric = enabled
+}
+
+// emitBool emits a boolean metric in the configured style
+func (c *StorageBoxCollector) emitBool(ch chan<- prometheus.Metric, desc *prometheus.Desc, value bool, labels ...string) {
+	if !c.stateset {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, boolToFloat64(value), labels...)
+		return
+	}
+	current := boolStates[1]
+	if value {
+		current = boolStates[0]
+	}
+	for _, state := range boolStates {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, boolToFloat64(state == current), append(labels, state)...)
+	}
+}
+
+// SetScrapeSummaryLog logs one info line per scrape with the number of boxes
+// collected, where they came from, the duration and the errors
+func (c *StorageBoxCollector) SetScrapeSummaryLog(enabled bool) {
+	c.summaryLog = enabled
+}
+
+// SetAPITimeout sets the deadline for fetching storage boxes from the API,
+// covering all pages and retries. Values of 0 or below keep the default.
+func (c *StorageBoxCollector) SetAPITimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = defaultAPITimeout
+	}
+	c.apiTimeout = timeout
+}
+
+// SetMinScrapeInterval serves the previous result without an API call while
+// the last complete fetch is younger than interval, whether or not the cache
+// is enabled. A value of 0 disables the guard.
+func (c *StorageBoxCollector) SetMinScrapeInterval(interval time.Duration) {
+	c.minInterval = interval
+}
+
+// SetUpFailureGrace keeps serving the last fetched data with up=1 while
+// fetches have been failing for less than grace. Authentication errors report
+// up=0 at once. A value of 0 disables the grace period.
+func (c *StorageBoxCollector) SetUpFailureGrace(grace time.Duration) {
+	c.failureGrace = grace
+}
+
+// SetSkipInactive omits per-box metrics for storage boxes whose status is not
+// active, as they may lack stats. Such boxes still count in the summary metrics.
+func (c *StorageBoxCollector) SetSkipInactive(skip bool) {
+	c.skipInactive.Store(skip)
+}
+
+// SetNameConvention exposes storagebox_name_convention_violation for every box,
+// flagging names that do not match convention. A nil convention disables it.
+func (c *StorageBoxCollector) SetNameConvention(convention *regexp.Regexp) {
+	c.nameConvention = convention
+}
+
+// SetRequiredLabels exposes storagebox_missing_required_label for every box
+// and each of the given label keys, flagging untagged boxes. An empty list
+// disables it.
+func (c *StorageBoxCollector) SetRequiredLabels(keys []string) {
+	c.requiredLabels = keys
+}
+
+// SetRedisCache replaces the in-memory cache with a Redis cache at redisURL
+// (redis://[[user]:password@]host[:port][/db]), so replicas of the exporter
+// share cached API data. The cache TTL is kept. Call it before the first
+// scrape. An unreachable Redis is only logged: scrapes then fall back to the
+// API until it becomes available.
+func (c *StorageBoxCollector) SetRedisCache(redisURL string) error {
+	redisCache, err := cache.NewRedisCache(redisURL, c.cache.TTL(), decodeCacheEntry)
+	if err != nil {
+		return err
+	}
+	if err := redisCache.Ping(); err != nil {
+		slog.Warn("Redis cache not reachable, scrapes call the API until it is", "error", err)
+	}
+	c.cache = redisCache
+	return nil
+}
+
+// cacheEntry is what the cache holds: the storage boxes of an account, or,
+// with snapshot metrics, the snapshot summary of one of its boxes under a key
+// of its own
+type cacheEntry struct {
+	Boxes    []hetzner.StorageBox `json:"boxes,omitempty"`
+	Snapshot *snapshotSummary     `json:"snapshot,omitempty"`
+}
+
+// snapshotCacheKey returns the cache key of the snapshot summary of a box
+func (c *StorageBoxCollector) snapshotCacheKey(id int64) string {
+	return c.client.CacheKey() + ":snap:" + formatInt64(id)
+}
+
+// cacheBoxes caches boxes, and the snapshot summaries fetched along with them
+// per box. Each entry expires on its own, so a summary that could not be
+// fetched never holds back the others.
+func (c *StorageBoxCollector) cacheBoxes(boxes []hetzner.StorageBox) {
+	c.cache.Set(c.client.CacheKey(), cacheEntry{Boxes: boxes})
+
+	c.stateMu.Lock()
+	snapshots := c.snapshots
+	c.stateMu.Unlock()
+	for id, summary := range snapshots {
+		c.cache.Set(c.snapshotCacheKey(id), cacheEntry{Snapshot: &summary})
+	}
+}
+
+// cachedBoxes returns the cache entry of the account, if any
+func (c *StorageBoxCollector) cachedBoxes() (cacheEntry, bool) {
+	data, found := c.cache.Get(c.client.CacheKey())
+	if !found {
+		return cacheEntry{}, false
+	}
+	return data.(cacheEntry), true
+}
+
+// cachedSnapshots returns the cached snapshot summaries of boxes. Boxes
+// without a cache entry are left out and lack the snapshot metrics.
+func (c *StorageBoxCollector) cachedSnapshots(boxes []hetzner.StorageBox) map[int64]snapshotSummary {
+	if !c.snapshotMetrics {
+		return nil
+	}
+	summaries := make(map[int64]snapshotSummary, len(boxes))
+	for _, box := range boxes {
+		if data, found := c.cache.Get(c.snapshotCacheKey(box.ID)); found {
+			if entry := data.(cacheEntry); entry.Snapshot != nil {
+				summaries[box.ID] = *entry.Snapshot
+			}
+		}
+	}
+	return summaries
+}
+
+// decodeCacheEntry decodes a cacheEntry cached as JSON by a RedisCache
+func decodeCacheEntry(raw []byte) (interface{}, error) {
+	var entry cacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, err
 	}
+	return entry, nil
+}
+
+// RunCacheCleanup drops expired entries of the in-memory cache every cleanup
+// interval until ctx is cancelled. Redis expires entries on its own, so it
+// returns at once with the Redis cache.
+func (c *StorageBoxCollector) RunCacheCleanup(ctx context.Context) {
+	if memoryCache, ok := c.cache.(*cache.MetricsCache); ok {
+		memoryCache.RunCleanup(ctx)
+	}
+}
+
+// SetCacheTTL changes the cache TTL at runtime, e.g. on a config reload. A TTL
+// of 0 disables the cache; cached data is dropped either way.
+func (c *StorageBoxCollector) SetCacheTTL(ttl time.Duration) {
+	c.cache.SetTTL(ttl)
+	c.cache.Clear()
+	c.cacheEnabled.Store(ttl > 0)
+}
+
+// SetTokenSource records how the API token was resolved (env, flag, file or
+// config_file) for storagebox_exporter_token_source. The token itself is
+// never exposed.
+func (c *StorageBoxCollector) SetTokenSource(source string) {
+	c.tokenSource = source
+}
+
+// RecordTokenReloadFailure counts a failed token file reload, e.g. after a bad
+// rotation left the file empty, while the previous token stays in use
+func (c *StorageBoxCollector) RecordTokenReloadFailure() {
+	c.tokenReloadFailures.Inc()
+}
+
+// SetPaused pauses or resumes Hetzner API calls. While paused, scrapes serve
+// the last successfully fetched data, e.g. during planned Hetzner maintenance.
+func (c *StorageBoxCollector) SetPaused(paused bool) {
+	c.paused.Store(paused)
+}
+
+// Paused reports whether API calls are currently paused
+func (c *StorageBoxCollector) Paused() bool {
+	return c.paused.Load()
+}
+
+// Ready reports whether the exporter is ready to serve meaningful metrics and,
+// if not, why. Transient server or rate limit errors keep it ready.
+func (c *StorageBoxCollector) Ready() (bool, string) {
+	return c.readiness.ready()
 }
 
 // Describe implements prometheus.Collector
 func (c *StorageBoxCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.renameDescs(ch, c.describe)
+}
+
+// describe sends the descriptors of all metrics, before label renames
+func (c *StorageBoxCollector) describe(ch chan<- *prometheus.Desc) {
+	c.describeStorageBox(ch)
+	ch <- c.typeBoxCount
+	ch <- c.statusBoxCount
+	ch <- c.boxesTotal
+	ch <- c.quotaMin
+	ch <- c.quotaMax
+	ch <- c.quotaAvg
+	ch <- c.accountQuota
+	ch <- c.accountUsage
+	ch <- c.sshCount
+	ch <- c.sambaCount
+	ch <- c.webdavCount
+	ch <- c.zfsCount
+	ch <- c.usageRatioDist
+	ch <- c.up
+	ch <- c.storageBoxUp
+	ch <- c.buildInfo
+	ch <- c.scrapeDuration
+	ch <- c.successRatio
+	ch <- c.pausedDesc
+	ch <- c.retriesDesc
+	ch <- c.readinessDesc
+	ch <- c.goroutineDelta
+	ch <- c.payloadBytes
+	ch <- c.pollIntervalDesc
+	ch <- c.lastPollDesc
+	ch <- c.lastSuccessDesc
+	ch <- c.heartbeat
+	ch <- c.partialDesc
+	ch <- c.truncatedDesc
+	ch <- c.deprecatedDesc
+	ch <- c.tokenGeneration
+	ch <- c.tokenSourceDesc
+	ch <- c.cacheBackend
+	ch <- c.redisUp
+	ch <- c.redisErrors
+	ch <- c.apiEndpoint
+	c.scrapeErrors.Describe(ch)
+	c.scrapesTotal.Describe(ch)
+	c.cacheHits.Describe(ch)
+	c.cacheMisses.Describe(ch)
+	c.duplicateNames.Describe(ch)
+	c.sharedServers.Describe(ch)
+	c.authErrors.Describe(ch)
+	c.rateLimitErrors.Describe(ch)
+	c.serverErrors.Describe(ch)
+	c.clientErrors.Describe(ch)
+	c.networkErrors.Describe(ch)
+	c.unexpectedResponses.Describe(ch)
+	c.scrapeCancelled.Describe(ch)
+	c.tokenReloadFailures.Describe(ch)
+}
+
+// describeStorageBox sends the descriptors of the per-box metrics
+func (c *StorageBoxCollector) describeStorageBox(ch chan<- *prometheus.Desc) {
 	ch <- c.diskQuota
 	ch <- c.diskUsage
 	ch <- c.diskUsageData
 	ch <- c.diskUsageSnapshots
+	ch <- c.diskUsageRatio
+	ch <- c.diskUsagePeak
+	ch <- c.usageDropRatio
+	ch <- c.snapshotRatio
+	ch <- c.snapshotDataRatio
 	ch <- c.info
 	ch <- c.status
+	ch <- c.typeChanges
+	ch <- c.nameViolation
+	ch <- c.missingLabel
 	ch <- c.accessSSH
 	ch <- c.accessSamba
 	ch <- c.accessWebDAV
 	ch <- c.accessZFS
+	ch <- c.access
 	ch <- c.reachableExternal
+	ch <- c.accessExternal
+	ch <- c.externalMismatch
 	ch <- c.snapshotPlan
+	ch <- c.snapshotPlanSet
+	ch <- c.snapshotMax
+	ch <- c.snapshotHour
+	ch <- c.snapshotWeekday
+	ch <- c.snapshotCount
+	ch <- c.snapshotOldest
 	ch <- c.protectionDelete
 	ch <- c.createdTimestamp
-	ch <- c.up
-	ch <- c.buildInfo
-	ch <- c.scrapeDuration
-	c.scrapeErrors.Describe(ch)
-	c.cacheHits.Describe(ch)
-	c.cacheMisses.Describe(ch)
-	c.authErrors.Describe(ch)
-	c.rateLimitErrors.Describe(ch)
-	c.serverErrors.Describe(ch)
-	c.clientErrors.Describe(ch)
-	c.networkErrors.Describe(ch)
+	ch <- c.daysSinceCreated
 }
 
 // Collect implements prometheus.Collector
 func (c *StorageBoxCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collect(ch, ScrapeOptions{})
+}
+
+// collect collects all metrics of a scrape with the given options
+func (c *StorageBoxCollector) collect(ch chan<- prometheus.Metric, opts ScrapeOptions) {
 	start := time.Now()
+	ch, flush := c.excludeFilter(ch)
+	defer flush()
+	ch, flushRenames := c.renameFilter(ch)
+	defer flushRenames()
+	c.scrapesTotal.Inc()
 
 	// build_info is static and always emitted, regardless of scrape outcome.
 	ch <- prometheus.MustNewConstMetric(
@@ -255,74 +1068,347 @@ func (c *StorageBoxCollector) Collect(ch chan<- prometheus.Metric) {
 		1,
 		c.buildInfoData.Version, c.buildInfoData.Commit, runtime.Version(), c.buildInfoData.BuildDate,
 	)
+	// The heartbeat is emitted regardless of the API outcome too, telling
+	// "not scraped" apart from "scrapes failing"
+	ch <- prometheus.MustNewConstMetric(c.heartbeat, prometheus.GaugeValue, float64(start.UnixNano())/1e9)
 
-	boxes, err := c.fetchBoxes()
-	if err != nil {
-		// Source unreachable/unparseable: report up=0 and omit storage box
-		// metrics (no misleading zeros or stale values), per the exporter blueprint.
-		c.emitExporterMetrics(ch, 0, time.Since(start).Seconds())
+	boxes, err := c.fetchBoxes(opts)
+	if errors.Is(err, context.Canceled) && opts.context().Err() != nil {
+		// Nobody waits for the metrics of an abandoned scrape anymore, and
+		// its cancellation says nothing about the API
 		return
 	}
+	if err != nil {
+		c.lastScrapeStatus.Store("error")
+		var graced bool
+		if boxes, graced = c.failureGraceBoxes(err); !graced {
+			// Source unreachable/unparseable: report up=0 and omit storage box
+			// metrics (no misleading zeros or stale values), per the exporter blueprint.
+			c.emitExporterMetrics(ch, 0, time.Since(start).Seconds())
+			c.logScrapeSummary(0, err, time.Since(start))
+			return
+		}
+	}
+
+	if err == nil {
+		c.lastScrapeStatus.Store("success")
+		c.lastSuccess.Store(time.Now().UnixNano())
+	}
 
+	boxCh, flushBoxes := c.pollTimestampFilter(ch, opts)
+	collected := 0
 	for _, box := range boxes {
-		c.collectStorageBox(ch, &box)
+		if c.skipInactive.Load() && box.Status != "active" {
+			continue
+		}
+		c.emitStorageBox(boxCh, &box, c.peekHistory(&box))
+		collected++
 	}
+	flushBoxes()
+	c.collectSummary(ch, boxes)
+	c.checkDuplicateNames(boxes)
+	c.checkSharedServers(boxes)
 
 	c.emitExporterMetrics(ch, 1, time.Since(start).Seconds())
+	c.logScrapeSummary(collected, err, time.Since(start))
+}
+
+// logScrapeSummary logs the summary line of a scrape if enabled. err is the
+// fetch error, which is set but not fatal while the failure grace applies.
+func (c *StorageBoxCollector) logScrapeSummary(boxes int, err error, duration time.Duration) {
+	if !c.summaryLog {
+		return
+	}
+	source, _ := c.lastSource.Load().(string)
+	attrs := []any{
+		"boxes", boxes,
+		"source", source,
+		"duration", duration,
+		"errors", 0,
+	}
+	if err != nil {
+		attrs[len(attrs)-1] = 1
+		attrs = append(attrs, "error", err)
+	}
+	slog.Info("Scrape summary", attrs...)
 }
 
-// fetchBoxes returns the storage boxes, using the cache when enabled. On error
-// it records the appropriate error counters via handleError.
-func (c *StorageBoxCollector) fetchBoxes() ([]hetzner.StorageBox, error) {
-	if c.cacheEnabled {
-		if cachedData, found := c.cache.Get(); found {
+// fetchBoxes returns the storage boxes, using the cache when enabled unless
+// opts asks for a refresh. On error it records the appropriate error counters
+// via handleError.
+func (c *StorageBoxCollector) fetchBoxes(opts ScrapeOptions) ([]hetzner.StorageBox, error) {
+	// In poll mode the retries and payload gauges describe the last poll
+	if c.pollInterval > 0 {
+		c.lastSource.Store("poll")
+		return c.polledBoxes()
+	}
+
+	// Scrapes served from the cache or while paused make no API requests
+	c.lastRetries.Store(0)
+	c.lastPayload.Store(0)
+	c.lastPartial.Store(false)
+	c.lastTruncated.Store(false)
+
+	if c.paused.Load() {
+		c.lastSource.Store("paused")
+		c.stateMu.Lock()
+		defer c.stateMu.Unlock()
+		if c.lastBoxes == nil {
+			return nil, errNoLastKnownData
+		}
+		return c.lastBoxes, nil
+	}
+
+	if opts.Refresh {
+		c.lastSource.Store("refresh")
+		boxes, partial, err := c.fetchFromAPI(opts.context(), "refresh")
+		if err == nil && !partial && c.cacheEnabled.Load() {
+			c.cacheBoxes(boxes)
+		}
+		return boxes, err
+	}
+
+	if boxes, ok := c.recentBoxes(); ok {
+		c.lastSource.Store("min_scrape_interval")
+		return boxes, nil
+	}
+
+	if c.cacheEnabled.Load() {
+		if cached, found := c.cachedBoxes(); found {
 			c.cacheHits.Inc()
-			return cachedData.([]hetzner.StorageBox), nil
+			c.lastSource.Store("cache_hit")
+			// Snapshot summaries may come from another replica's fetch
+			snapshots := c.cachedSnapshots(cached.Boxes)
+			c.stateMu.Lock()
+			c.snapshots = snapshots
+			c.stateMu.Unlock()
+			return cached.Boxes, nil
 		}
 		c.cacheMisses.Inc()
+		c.lastSource.Store("cache_miss")
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		boxes, err := c.client.ListStorageBoxes(ctx)
+		boxes, partial, err := c.fetchFromAPI(opts.context(), "cache_miss")
 		if err != nil {
-			c.handleError(err, "cache_miss")
 			return nil, err
 		}
-		c.cache.Set(boxes)
+		// Partial results are served once but never cached
+		if !partial {
+			c.cacheBoxes(boxes)
+		}
 		return boxes, nil
 	}
 
 	// Cache disabled - always fetch from API
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	c.lastSource.Store("direct_api_call")
+	boxes, _, err := c.fetchFromAPI(opts.context(), "direct_api_call")
+	return boxes, err
+}
+
+// fetchFromAPI lists the storage boxes for a scrape or a poll and records
+// them in the per-box history
+func (c *StorageBoxCollector) fetchFromAPI(ctx context.Context, source string) ([]hetzner.StorageBox, bool, error) {
+	boxes, partial, err := c.listStorageBoxes(ctx, source)
+	if err == nil {
+		c.recordHistory(boxes)
+	}
+	return boxes, partial, err
+}
+
+// listStorageBoxes calls the Hetzner API and records the outcome of the call.
+// partial reports that pagination failed midway and only some storage boxes
+// were fetched, which is only possible with partial pagination enabled. The
+// call is abandoned once parent is cancelled; such calls only count towards
+// storagebox_exporter_scrape_cancelled_total, not as API failures.
+func (c *StorageBoxCollector) listStorageBoxes(parent context.Context, source string) (boxes []hetzner.StorageBox, partial bool, err error) {
+	ctx, cancel := context.WithTimeout(parent, c.apiTimeout)
 	defer cancel()
 
-	boxes, err := c.client.ListStorageBoxes(ctx)
-	if err != nil {
-		c.handleError(err, "direct_api_call")
-		return nil, err
+	var stats hetzner.RequestStats
+	boxes, err = c.client.ListStorageBoxes(hetzner.WithRequestStats(ctx, &stats))
+	if errors.Is(err, context.Canceled) && parent.Err() != nil {
+		c.scrapeCancelled.Inc()
+		slog.Warn("Hetzner API call cancelled",
+			"error", err,
+			"source", source,
+		)
+		return nil, false, err
+	}
+	c.recordRequestStats(&stats)
+	c.apiOutcomes.record(err == nil)
+
+	var partialErr *hetzner.PartialResultError
+	partial = errors.As(err, &partialErr)
+	c.lastPartial.Store(partial)
+	if partial {
+		c.readiness.recordFailure(hetzner.IsAuthError(partialErr.Err))
+		c.handleError(partialErr.Err, source)
+		slog.Warn("Serving partial storage box data after a failed page",
+			"boxes", len(boxes),
+			"error", partialErr.Err,
+			"source", source,
+		)
+	} else if err != nil {
+		c.readiness.recordFailure(hetzner.IsAuthError(err))
+		c.handleError(err, source)
+		return nil, false, err
+	} else {
+		c.readiness.recordSuccess()
+	}
+
+	// The snapshot listings add their retries, payload and deprecation
+	// headers to those of the storage boxes
+	c.fetchSnapshots(hetzner.WithRequestStats(parent, &stats), boxes)
+	c.recordRequestStats(&stats)
+
+	c.stateMu.Lock()
+	c.lastBoxes = boxes
+	if !partial {
+		c.lastFetch = time.Now()
+	}
+	c.failingSince = time.Time{}
+	c.stateMu.Unlock()
+
+	return boxes, partial, nil
+}
+
+// recordRequestStats keeps the request stats of the last fetch for the
+// exporter metrics
+func (c *StorageBoxCollector) recordRequestStats(stats *hetzner.RequestStats) {
+	c.lastRetries.Store(stats.Retries())
+	c.lastPayload.Store(stats.PayloadBytes())
+	c.lastTruncated.Store(stats.Truncated())
+	c.apiDeprecated.Store(stats.Deprecated())
+}
+
+// failureGraceBoxes returns the last fetched storage boxes while fetches have
+// been failing for less than the failure grace period, so that transient errors
+// do not flip up to 0. Authentication errors are never graced.
+func (c *StorageBoxCollector) failureGraceBoxes(err error) ([]hetzner.StorageBox, bool) {
+	if c.failureGrace <= 0 || hetzner.IsAuthError(err) {
+		return nil, false
+	}
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.failingSince.IsZero() {
+		c.failingSince = time.Now()
+	}
+	if c.lastBoxes == nil || time.Since(c.failingSince) >= c.failureGrace {
+		return nil, false
+	}
+	slog.Warn("Serving last fetched storage boxes within the failure grace period",
+		"error", err,
+		"failing_for", time.Since(c.failingSince),
+		"grace", c.failureGrace,
+	)
+	return c.lastBoxes, true
+}
+
+// recentBoxes returns the last fetched storage boxes if the fetch happened
+// within the minimum scrape interval.
+func (c *StorageBoxCollector) recentBoxes() ([]hetzner.StorageBox, bool) {
+	if c.minInterval <= 0 {
+		return nil, false
+	}
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.lastFetch.IsZero() || time.Since(c.lastFetch) >= c.minInterval {
+		return nil, false
 	}
-	return boxes, nil
+	return c.lastBoxes, true
 }
 
 // emitExporterMetrics emits the exporter-level metrics (up, scrape duration and
 // all counters) shared by both the success and failure paths.
 func (c *StorageBoxCollector) emitExporterMetrics(ch chan<- prometheus.Metric, up, duration float64) {
 	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, up)
+	ch <- prometheus.MustNewConstMetric(c.storageBoxUp, prometheus.GaugeValue, up)
 	ch <- prometheus.MustNewConstMetric(c.scrapeDuration, prometheus.GaugeValue, duration)
+	ch <- prometheus.MustNewConstMetric(c.pausedDesc, prometheus.GaugeValue, boolToFloat64(c.paused.Load()))
+	ch <- prometheus.MustNewConstMetric(c.retriesDesc, prometheus.GaugeValue, float64(c.lastRetries.Load()))
+	ready, _ := c.readiness.ready()
+	ch <- prometheus.MustNewConstMetric(c.readinessDesc, prometheus.GaugeValue, boolToFloat64(ready))
+	ch <- prometheus.MustNewConstMetric(c.payloadBytes, prometheus.GaugeValue, float64(c.lastPayload.Load()))
+	ch <- prometheus.MustNewConstMetric(c.partialDesc, prometheus.GaugeValue, boolToFloat64(c.lastPartial.Load()))
+	ch <- prometheus.MustNewConstMetric(c.truncatedDesc, prometheus.GaugeValue, boolToFloat64(c.lastTruncated.Load()))
+	ch <- prometheus.MustNewConstMetric(c.deprecatedDesc, prometheus.GaugeValue, boolToFloat64(c.apiDeprecated.Load()))
+	ch <- prometheus.MustNewConstMetric(c.tokenGeneration, prometheus.GaugeValue, float64(c.client.TokenGeneration()))
+	if c.tokenSource != "" {
+		ch <- prometheus.MustNewConstMetric(c.tokenSourceDesc, prometheus.GaugeValue, 1, c.tokenSource)
+	}
+	ch <- prometheus.MustNewConstMetric(c.cacheBackend, prometheus.GaugeValue, 1, c.cache.Backend())
+	if redisCache, ok := c.cache.(*cache.RedisCache); ok {
+		ch <- prometheus.MustNewConstMetric(c.redisUp, prometheus.GaugeValue, boolToFloat64(redisCache.Up()))
+		ch <- prometheus.MustNewConstMetric(c.redisErrors, prometheus.CounterValue, float64(redisCache.Errors()))
+	}
+	ch <- prometheus.MustNewConstMetric(c.apiEndpoint, prometheus.GaugeValue, 1, c.client.BaseURL())
+	ch <- prometheus.MustNewConstMetric(c.goroutineDelta, prometheus.GaugeValue, float64(c.goroutinesDelta(runtime.NumGoroutine())))
+	if c.pollInterval > 0 {
+		ch <- prometheus.MustNewConstMetric(c.pollIntervalDesc, prometheus.GaugeValue, c.pollInterval.Seconds())
+		if lastPoll := c.lastPoll.Load(); lastPoll > 0 {
+			ch <- prometheus.MustNewConstMetric(c.lastPollDesc, prometheus.GaugeValue, float64(lastPoll)/1e9)
+		}
+	}
+	if lastSuccess := c.lastSuccess.Load(); lastSuccess > 0 {
+		ch <- prometheus.MustNewConstMetric(c.lastSuccessDesc, prometheus.GaugeValue, float64(lastSuccess)/1e9)
+	}
+	if ratio, ok := c.apiOutcomes.ratio(); ok {
+		ch <- prometheus.MustNewConstMetric(c.successRatio, prometheus.GaugeValue, ratio)
+	}
 
 	c.scrapeErrors.Collect(ch)
+	c.scrapesTotal.Collect(ch)
 	c.cacheHits.Collect(ch)
 	c.cacheMisses.Collect(ch)
+	c.duplicateNames.Collect(ch)
+	c.sharedServers.Collect(ch)
 	c.authErrors.Collect(ch)
 	c.rateLimitErrors.Collect(ch)
 	c.serverErrors.Collect(ch)
 	c.clientErrors.Collect(ch)
 	c.networkErrors.Collect(ch)
+	c.unexpectedResponses.Collect(ch)
+	c.scrapeCancelled.Collect(ch)
+	c.tokenReloadFailures.Collect(ch)
+}
+
+// boxHistory holds the per-box values derived from the API fetches so far
+type boxHistory struct {
+	peakUsage   int64
+	usageDrop   float64
+	typeChanges int
+}
+
+// recordHistory records the usage and type of boxes freshly fetched from the
+// API in the per-box history. Boxes served again, from the cache, within the
+// minimum scrape interval or while paused, are not recorded, so usage drops
+// and type changes compare API fetches rather than scrapes.
+func (c *StorageBoxCollector) recordHistory(boxes []hetzner.StorageBox) {
+	for _, box := range boxes {
+		if c.skipInactive.Load() && box.Status != "active" {
+			continue
+		}
+		c.recordPeakUsage(box.ID, int64(box.Stats.Size))
+		c.recordUsageDrop(box.ID, int64(box.Stats.Size))
+		c.recordTypeChange(box.ID, box.StorageBoxType.Name)
+	}
+}
+
+// peekHistory returns the history of box as recorded by the last API fetch,
+// without recording box. The peak usage includes the usage of box.
+func (c *StorageBoxCollector) peekHistory(box *hetzner.StorageBox) boxHistory {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	return boxHistory{
+		peakUsage:   max(c.peakUsage[box.ID], int64(box.Stats.Size)),
+		usageDrop:   c.lastDrop[box.ID],
+		typeChanges: c.typeChangeCount[box.ID],
+	}
 }
 
-// collectStorageBox collects metrics for a single storage box
-func (c *StorageBoxCollector) collectStorageBox(ch chan<- prometheus.Metric, box *hetzner.StorageBox) {
+// emitStorageBox collects the metrics of a single storage box with the given
+// history. It leaves the collector state untouched.
+func (c *StorageBoxCollector) emitStorageBox(ch chan<- prometheus.Metric, box *hetzner.StorageBox, history boxHistory) {
 	id := formatInt64(box.ID)
 	name := box.Name
 	server := box.Server
@@ -333,102 +1419,188 @@ func (c *StorageBoxCollector) collectStorageBox(ch chan<- prometheus.Metric, box
 	ch <- prometheus.MustNewConstMetric(
 		c.diskQuota,
 		prometheus.GaugeValue,
-		float64(box.StorageBoxType.Size),
+		c.size(int64(box.StorageBoxType.Size)),
 		id, name, server, location,
 	)
 
 	ch <- prometheus.MustNewConstMetric(
 		c.diskUsage,
 		prometheus.GaugeValue,
-		float64(box.Stats.Size),
+		c.size(int64(box.Stats.Size)),
 		id, name, server, location,
 	)
 
 	ch <- prometheus.MustNewConstMetric(
 		c.diskUsageData,
 		prometheus.GaugeValue,
-		float64(box.Stats.SizeData),
+		c.size(int64(box.Stats.SizeData)),
 		id, name, server, location,
 	)
 
 	ch <- prometheus.MustNewConstMetric(
 		c.diskUsageSnapshots,
 		prometheus.GaugeValue,
-		float64(box.Stats.SizeSnapshots),
+		c.size(int64(box.Stats.SizeSnapshots)),
 		id, name, server, location,
 	)
 
-	// Info metric
+	// Usage relative to the quota, 0 for boxes without a known quota
+	usageRatio := float64(0)
+	if box.StorageBoxType.Size > 0 {
+		usageRatio = float64(box.Stats.Size) / float64(box.StorageBoxType.Size)
+	}
 	ch <- prometheus.MustNewConstMetric(
-		c.info,
+		c.diskUsageRatio,
 		prometheus.GaugeValue,
-		1,
-		id, name, box.Username, server, location, box.StorageBoxType.Name, box.System,
+		usageRatio,
+		id, name, server, location,
 	)
 
-	// Status metric (always 1, status value in label)
+	if c.usageCounter {
+		ch <- prometheus.MustNewConstMetric(
+			c.diskUsagePeak,
+			prometheus.CounterValue,
+			c.size(history.peakUsage),
+			id, name, server, location,
+		)
+	}
+
 	ch <- prometheus.MustNewConstMetric(
-		c.status,
+		c.usageDropRatio,
 		prometheus.GaugeValue,
-		1,
-		id, name, box.Status,
+		history.usageDrop,
+		id, name,
 	)
 
-	// Access settings metrics
+	// Snapshot share of total usage, 0 for boxes without any usage
+	snapshotRatio := float64(0)
+	if box.Stats.Size > 0 {
+		snapshotRatio = float64(box.Stats.SizeSnapshots) / float64(box.Stats.Size)
+	}
 	ch <- prometheus.MustNewConstMetric(
-		c.accessSSH,
+		c.snapshotRatio,
 		prometheus.GaugeValue,
-		boolToFloat64(box.AccessSettings.SSH),
+		snapshotRatio,
 		id, name,
 	)
 
+	// Snapshots relative to file data, 0 for boxes without file data
+	snapshotDataRatio := float64(0)
+	if box.Stats.SizeData > 0 {
+		snapshotDataRatio = float64(box.Stats.SizeSnapshots) / float64(box.Stats.SizeData)
+	}
 	ch <- prometheus.MustNewConstMetric(
-		c.accessSamba,
+		c.snapshotDataRatio,
 		prometheus.GaugeValue,
-		boolToFloat64(box.AccessSettings.Samba),
+		snapshotDataRatio,
 		id, name,
 	)
 
+	// Info metric
+	infoLabels := []string{id, name, box.Username, server, location, box.StorageBoxType.Name, box.System}
+	if c.createdLabel {
+		infoLabels = append(infoLabels, box.Created.UTC().Format(time.RFC3339))
+	}
+	for _, key := range c.exportedLabels {
+		infoLabels = append(infoLabels, box.Labels[key])
+	}
 	ch <- prometheus.MustNewConstMetric(
-		c.accessWebDAV,
+		c.info,
 		prometheus.GaugeValue,
-		boolToFloat64(box.AccessSettings.WebDAV),
-		id, name,
+		1,
+		infoLabels...,
 	)
 
+	// Status metric (always 1, status value in label)
 	ch <- prometheus.MustNewConstMetric(
-		c.accessZFS,
+		c.status,
 		prometheus.GaugeValue,
-		boolToFloat64(box.AccessSettings.ZFS),
-		id, name,
+		1,
+		id, name, box.Status,
 	)
 
+	if c.nameConvention != nil {
+		ch <- prometheus.MustNewConstMetric(
+			c.nameViolation,
+			prometheus.GaugeValue,
+			boolToFloat64(!c.nameConvention.MatchString(name)),
+			id, name,
+		)
+	}
+
+	for _, key := range c.requiredLabels {
+		_, present := box.Labels[key]
+		ch <- prometheus.MustNewConstMetric(
+			c.missingLabel,
+			prometheus.GaugeValue,
+			boolToFloat64(!present),
+			id, name, key,
+		)
+	}
+
 	ch <- prometheus.MustNewConstMetric(
-		c.reachableExternal,
-		prometheus.GaugeValue,
-		boolToFloat64(box.AccessSettings.ReachableExternally),
+		c.typeChanges,
+		prometheus.CounterValue,
+		float64(history.typeChanges),
 		id, name,
 	)
 
-	// Snapshot plan metric
-	snapshotEnabled := float64(0)
-	if box.SnapshotPlan != nil && box.SnapshotPlan.Enabled {
-		snapshotEnabled = 1
+	// Access settings metrics
+	c.emitBool(ch, c.accessSSH, box.AccessSettings.SSH, id, name)
+	c.emitBool(ch, c.accessSamba, box.AccessSettings.Samba, id, name)
+	c.emitBool(ch, c.accessWebDAV, box.AccessSettings.WebDAV, id, name)
+	c.emitBool(ch, c.accessZFS, box.AccessSettings.ZFS, id, name)
+
+	// Same settings with the protocol as a label, for queries across protocols
+	access := box.AccessSettings
+	if c.protocolMetric {
+		for _, p := range []struct {
+			protocol string
+			enabled  bool
+		}{
+			{"ssh", access.SSH},
+			{"samba", access.Samba},
+			{"webdav", access.WebDAV},
+			{"zfs", access.ZFS},
+		} {
+			c.emitBool(ch, c.access, p.enabled, id, name, p.protocol)
+		}
 	}
+
+	c.emitBool(ch, c.reachableExternal, box.AccessSettings.ReachableExternally, id, name)
+	if c.externalAlias {
+		c.emitBool(ch, c.accessExternal, box.AccessSettings.ReachableExternally, id, name)
+	}
+
+	// Protocols enabled on a box that is not reachable externally may be an
+	// intentional internal-only setup or a misconfiguration worth reviewing
+	protocolsEnabled := access.SSH || access.Samba || access.WebDAV || access.ZFS
 	ch <- prometheus.MustNewConstMetric(
-		c.snapshotPlan,
+		c.externalMismatch,
 		prometheus.GaugeValue,
-		snapshotEnabled,
+		boolToFloat64(protocolsEnabled && !access.ReachableExternally),
 		id, name,
 	)
 
-	// Protection metric
+	// Snapshot plan metric
+	c.emitBool(ch, c.snapshotPlan, box.SnapshotPlan != nil && box.SnapshotPlan.Enabled, id, name)
 	ch <- prometheus.MustNewConstMetric(
-		c.protectionDelete,
+		c.snapshotPlanSet,
 		prometheus.GaugeValue,
-		boolToFloat64(box.Protection.Delete),
+		boolToFloat64(box.SnapshotPlan != nil),
 		id, name,
 	)
+	if plan := box.SnapshotPlan; plan != nil {
+		ch <- prometheus.MustNewConstMetric(c.snapshotMax, prometheus.GaugeValue, float64(plan.MaxSnapshots), id, name)
+		ch <- prometheus.MustNewConstMetric(c.snapshotHour, prometheus.GaugeValue, float64(plan.Hour), id, name)
+		if plan.DayOfWeek != nil {
+			ch <- prometheus.MustNewConstMetric(c.snapshotWeekday, prometheus.GaugeValue, float64(*plan.DayOfWeek), id, name)
+		}
+	}
+	c.collectSnapshots(ch, box, id, name)
+
+	// Protection metric
+	c.emitBool(ch, c.protectionDelete, box.Protection.Delete, id, name)
 
 	// Created timestamp metric
 	ch <- prometheus.MustNewConstMetric(
@@ -437,6 +1609,208 @@ func (c *StorageBoxCollector) collectStorageBox(ch chan<- prometheus.Metric, box
 		float64(box.Created.Unix()),
 		id, name,
 	)
+
+	// Days since creation, omitted for missing or future creation times
+	if age := time.Since(box.Created); !box.Created.IsZero() && age >= 0 {
+		ch <- prometheus.MustNewConstMetric(
+			c.daysSinceCreated,
+			prometheus.GaugeValue,
+			float64(int64(age/(24*time.Hour))),
+			id, name,
+		)
+	}
+}
+
+// recordPeakUsage stores usage if it exceeds the peak seen so far for the box
+func (c *StorageBoxCollector) recordPeakUsage(id, usage int64) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if usage > c.peakUsage[id] {
+		c.peakUsage[id] = usage
+	}
+}
+
+// recordUsageDrop stores usage for the box along with its fractional decrease
+// from the usage recorded by the previous fetch, 0 on the first fetch or when
+// usage did not decrease
+func (c *StorageBoxCollector) recordUsageDrop(id, usage int64) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	previous, seen := c.lastUsage[id]
+	c.lastUsage[id] = usage
+	drop := float64(0)
+	if seen && previous > 0 && usage < previous {
+		drop = float64(previous-usage) / float64(previous)
+	}
+	c.lastDrop[id] = drop
+}
+
+// recordTypeChange stores the storage box type of the box, counting how many
+// times it changed since exporter start. The first observation is no change.
+func (c *StorageBoxCollector) recordTypeChange(id int64, boxType string) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	if previous, seen := c.lastType[id]; seen && previous != boxType {
+		c.typeChangeCount[id]++
+	}
+	c.lastType[id] = boxType
+}
+
+// goroutinesDelta records current as the goroutine count of this scrape and
+// returns its change since the previous scrape, 0 on the first scrape
+func (c *StorageBoxCollector) goroutinesDelta(current int) int {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	delta := 0
+	if c.lastGoroutines > 0 {
+		delta = current - c.lastGoroutines
+	}
+	c.lastGoroutines = current
+	return delta
+}
+
+// collectSummary collects fleet-level metrics aggregated across all storage boxes
+func (c *StorageBoxCollector) collectSummary(ch chan<- prometheus.Metric, boxes []hetzner.StorageBox) {
+	// Always emitted on success so an empty account is distinguishable from a
+	// failed scrape
+	ch <- prometheus.MustNewConstMetric(c.boxesTotal, prometheus.GaugeValue, float64(len(boxes)))
+
+	typeCounts := make(map[string]int)
+	statusCounts := make(map[string]int)
+	for _, box := range boxes {
+		typeCounts[box.StorageBoxType.Name]++
+		statusCounts[box.Status]++
+	}
+	for status, count := range statusCounts {
+		ch <- prometheus.MustNewConstMetric(
+			c.statusBoxCount,
+			prometheus.GaugeValue,
+			float64(count),
+			status,
+		)
+	}
+	for boxType, count := range typeCounts {
+		ch <- prometheus.MustNewConstMetric(
+			c.typeBoxCount,
+			prometheus.GaugeValue,
+			float64(count),
+			boxType,
+		)
+	}
+
+	// Account totals, 0 for empty accounts
+	var totalQuota, totalUsage float64
+	for _, box := range boxes {
+		totalQuota += float64(box.StorageBoxType.Size)
+		totalUsage += float64(box.Stats.Size)
+	}
+	ch <- prometheus.MustNewConstMetric(c.accountQuota, prometheus.GaugeValue, totalQuota)
+	ch <- prometheus.MustNewConstMetric(c.accountUsage, prometheus.GaugeValue, totalUsage)
+
+	// Access posture across the account, 0 when no box has a protocol on
+	var ssh, samba, webdav, zfs float64
+	for _, box := range boxes {
+		ssh += boolToFloat64(box.AccessSettings.SSH)
+		samba += boolToFloat64(box.AccessSettings.Samba)
+		webdav += boolToFloat64(box.AccessSettings.WebDAV)
+		zfs += boolToFloat64(box.AccessSettings.ZFS)
+	}
+	ch <- prometheus.MustNewConstMetric(c.sshCount, prometheus.GaugeValue, ssh)
+	ch <- prometheus.MustNewConstMetric(c.sambaCount, prometheus.GaugeValue, samba)
+	ch <- prometheus.MustNewConstMetric(c.webdavCount, prometheus.GaugeValue, webdav)
+	ch <- prometheus.MustNewConstMetric(c.zfsCount, prometheus.GaugeValue, zfs)
+
+	// Usage ratios of the current boxes with a known quota, built anew on
+	// every scrape so boxes are not counted again on the next one
+	usageRatios := prometheus.NewHistogram(usageRatioOpts)
+	for _, box := range boxes {
+		if box.StorageBoxType.Size > 0 {
+			usageRatios.Observe(float64(box.Stats.Size) / float64(box.StorageBoxType.Size))
+		}
+	}
+	var usageRatioData dto.Metric
+	if err := usageRatios.Write(&usageRatioData); err == nil {
+		ch <- histogramMetric{desc: c.usageRatioDist, histogram: usageRatioData.Histogram}
+	}
+
+	// Quota spread, omitted for empty accounts where it is undefined
+	if len(boxes) == 0 {
+		return
+	}
+	minQuota, maxQuota := boxes[0].StorageBoxType.Size, boxes[0].StorageBoxType.Size
+	for _, box := range boxes {
+		minQuota = min(minQuota, box.StorageBoxType.Size)
+		maxQuota = max(maxQuota, box.StorageBoxType.Size)
+	}
+	ch <- prometheus.MustNewConstMetric(c.quotaMin, prometheus.GaugeValue, float64(minQuota))
+	ch <- prometheus.MustNewConstMetric(c.quotaMax, prometheus.GaugeValue, float64(maxQuota))
+	ch <- prometheus.MustNewConstMetric(c.quotaAvg, prometheus.GaugeValue, totalQuota/float64(len(boxes)))
+}
+
+// checkDuplicateNames counts and warns about storage box names that became
+// shared by several boxes since the last check. Names are not unique in
+// Hetzner, so name-based queries may be ambiguous; every per-box metric carries
+// the id label to tell such boxes apart.
+func (c *StorageBoxCollector) checkDuplicateNames(boxes []hetzner.StorageBox) {
+	idsByName := make(map[string][]int64)
+	for _, box := range boxes {
+		idsByName[box.Name] = append(idsByName[box.Name], box.ID)
+	}
+	for _, name := range c.newlyShared(idsByName, &c.sharedNames) {
+		c.duplicateNames.Inc()
+		slog.Warn("Multiple storage boxes share the same name, use the id label to distinguish them",
+			"name", name,
+			"ids", idsByName[name],
+		)
+	}
+}
+
+// checkSharedServers counts and warns about server hostnames that became
+// shared by more than one storage box since the last check. Per-box metrics
+// stay distinct through the id label, but aggregations by server alone would
+// double-count such boxes.
+func (c *StorageBoxCollector) checkSharedServers(boxes []hetzner.StorageBox) {
+	idsByServer := make(map[string][]int64)
+	for _, box := range boxes {
+		if box.Server == "" {
+			continue
+		}
+		idsByServer[box.Server] = append(idsByServer[box.Server], box.ID)
+	}
+	for _, server := range c.newlyShared(idsByServer, &c.sharedHosts) {
+		c.sharedServers.Inc()
+		slog.Warn("Multiple storage boxes share the same server, aggregations by the server label alone double-count them",
+			"server", server,
+			"ids", idsByServer[server],
+		)
+	}
+}
+
+// newlyShared returns the keys of idsByKey shared by more than one box that
+// were not shared at the last check, and stores the shared keys in *shared
+// for the next check. Scrapes serving the same boxes again thus neither count
+// nor warn again.
+func (c *StorageBoxCollector) newlyShared(idsByKey map[string][]int64, shared *map[string]bool) []string {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	current := make(map[string]bool)
+	var added []string
+	for key, ids := range idsByKey {
+		if len(ids) < 2 {
+			continue
+		}
+		current[key] = true
+		if !(*shared)[key] {
+			added = append(added, key)
+		}
+	}
+	*shared = current
+	return added
 }
 
 // handleError processes an error and increments the appropriate error counter
@@ -465,12 +1839,24 @@ func (c *StorageBoxCollector) handleError(err error, source string) {
 			"is_retryable", hetzner.IsRetryableError(err),
 			"is_auth_error", hetzner.IsAuthError(err),
 		)
+	} else if errors.Is(err, hetzner.ErrUnexpectedResponse) {
+		c.unexpectedResponses.Inc()
+		slog.Error("Unexpected Hetzner API response",
+			"error", err,
+			"error_type", "unexpected_response",
+			"source", source,
+		)
 	} else {
 		// Non-API errors (network, timeouts, etc.)
 		c.networkErrors.Inc()
+		// The top-level message may hide the layer that failed, e.g. a DNS
+		// lookup under dial under the HTTP request
+		chain, rootCause := errorChain(err)
 		slog.Error("Network or system error occurred",
 			"error", err,
 			"error_type", "network",
+			"error_chain", chain,
+			"root_cause", rootCause,
 			"source", source,
 		)
 	}
@@ -479,6 +1865,80 @@ func (c *StorageBoxCollector) handleError(err error, source string) {
 	c.scrapeErrors.Inc()
 }
 
+// errorChain returns the types of err and the errors it wraps from the outside
+// in, e.g. "*url.Error -> *net.OpError -> *net.DNSError", and the message of
+// the innermost error. Of errors wrapping several errors, the last one is
+// followed, which is the cause in messages like "context: %w: %w".
+func errorChain(err error) (chain string, rootCause string) {
+	var types []string
+	for err != nil {
+		types = append(types, fmt.Sprintf("%T", err))
+		rootCause = err.Error()
+		switch wrapped := err.(type) {
+		case interface{ Unwrap() error }:
+			err = wrapped.Unwrap()
+		case interface{ Unwrap() []error }:
+			errs := wrapped.Unwrap()
+			err = nil
+			if len(errs) > 0 {
+				err = errs[len(errs)-1]
+			}
+		default:
+			err = nil
+		}
+	}
+	return strings.Join(types, " -> "), rootCause
+}
+
+// outcomeWindow is a fixed-size ring buffer of recent call outcomes
+type outcomeWindow struct {
+	mu        sync.Mutex
+	outcomes  []bool
+	next      int
+	count     int
+	successes int
+}
+
+// newOutcomeWindow creates a window holding the given number of outcomes
+// (at least one)
+func newOutcomeWindow(size int) *outcomeWindow {
+	if size < 1 {
+		size = 1
+	}
+	return &outcomeWindow{outcomes: make([]bool, size)}
+}
+
+// record adds an outcome, evicting the oldest one once the window is full
+func (w *outcomeWindow) record(success bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.count == len(w.outcomes) {
+		if w.outcomes[w.next] {
+			w.successes--
+		}
+	} else {
+		w.count++
+	}
+	w.outcomes[w.next] = success
+	if success {
+		w.successes++
+	}
+	w.next = (w.next + 1) % len(w.outcomes)
+}
+
+// ratio returns the fraction of successful outcomes in the window. ok is false
+// when no outcome has been recorded yet.
+func (w *outcomeWindow) ratio() (ratio float64, ok bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.count == 0 {
+		return 0, false
+	}
+	return float64(w.successes) / float64(w.count), true
+}
+
 // Helper functions
 
 func formatInt64(i int64) string {
@@ -1,14 +1,26 @@
 package collector
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/crstian19/prometheus-storagebox-exporter/internal/hetzner"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 // mockStorageBoxResponse creates a mock API response with storage boxes
@@ -136,8 +148,8 @@ func TestNewStorageBoxCollector(t *testing.T) {
 			if collector.client != client {
 				t.Error("expected client to be set")
 			}
-			if collector.cacheEnabled != tt.expectCacheEnabled {
-				t.Errorf("expected cacheEnabled=%v, got %v", tt.expectCacheEnabled, collector.cacheEnabled)
+			if collector.cacheEnabled.Load() != tt.expectCacheEnabled {
+				t.Errorf("expected cacheEnabled=%v, got %v", tt.expectCacheEnabled, collector.cacheEnabled.Load())
 			}
 		})
 	}
@@ -165,6 +177,55 @@ func TestDescribe(t *testing.T) {
 	}
 }
 
+// TestDescribeCoversCollect guards against metrics that are collected but not
+// described, which strict registries reject
+func TestDescribeCoversCollect(t *testing.T) {
+	for _, stateset := range []bool{false, true} {
+		_, collector := newMockRegistry(t, mockStorageBoxResponse())
+		// Enable the optional per-box metrics as well
+		collector.SetBooleanStyle(stateset)
+		collector.SetUsageCounter(true)
+		collector.SetNameConvention(regexp.MustCompile(`^test-`))
+		collector.SetRequiredLabels([]string{"owner"})
+		collector.SetExternalAccessAlias(true)
+
+		descCh := make(chan *prometheus.Desc)
+		go func() {
+			collector.Describe(descCh)
+			close(descCh)
+		}()
+		described := make(map[string]bool)
+		for desc := range descCh {
+			described[desc.String()] = true
+		}
+
+		metricCh := make(chan prometheus.Metric)
+		go func() {
+			collector.Collect(metricCh)
+			close(metricCh)
+		}()
+		collected := make(map[string]bool)
+		for metric := range metricCh {
+			collected[metric.Desc().String()] = true
+		}
+
+		for _, name := range []string{"storagebox_access_zfs_enabled", "storagebox_reachable_externally"} {
+			found := false
+			for desc := range collected {
+				found = found || strings.Contains(desc, `fqName: "`+name+`"`)
+			}
+			if !found {
+				t.Errorf("stateset=%v: expected %s to be collected", stateset, name)
+			}
+		}
+		for desc := range collected {
+			if !described[desc] {
+				t.Errorf("stateset=%v: collected metric is not described: %s", stateset, desc)
+			}
+		}
+	}
+}
+
 func TestCollectSuccess(t *testing.T) {
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Authorization") != "Bearer test-token" {
@@ -497,6 +558,105 @@ func TestHandleErrorNetworkError(t *testing.T) {
 	// Should not panic
 }
 
+func TestScrapesTotal(t *testing.T) {
+	var fail atomic.Bool
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	for i := 1; i <= 3; i++ {
+		// Failed scrapes count as well
+		fail.Store(i == 2)
+		if got := counterValue(t, reg, "storagebox_exporter_scrapes_total"); got != float64(i) {
+			t.Errorf("expected scrapes_total=%d after %d collections, got %v", i, i, got)
+		}
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	var fail atomic.Bool
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	collector.client.SetMaxAttempts(1)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	before := float64(time.Now().UnixNano()) / 1e9
+	first := gaugeValue(t, reg, "storagebox_exporter_heartbeat")
+	if first < before || first > float64(time.Now().UnixNano())/1e9 {
+		t.Fatalf("expected the heartbeat at the scrape time, got %v", first)
+	}
+
+	// The heartbeat keeps advancing while the API fails
+	fail.Store(true)
+	time.Sleep(10 * time.Millisecond)
+	if got := gaugeValue(t, reg, "storagebox_exporter_heartbeat"); got <= first {
+		t.Errorf("expected the heartbeat to advance on a failed scrape, got %v after %v", got, first)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != 0 {
+		t.Errorf("expected up=0 while the API fails, got %v", got)
+	}
+}
+
+func TestCollectMissingStorageBoxesKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantUp     float64
+		wantErrors float64
+	}{
+		{name: "missing key", body: `{"meta": {}}`, wantUp: 0, wantErrors: 1},
+		{name: "null", body: `{"storage_boxes": null}`, wantUp: 0, wantErrors: 1},
+		{name: "empty account", body: `{"storage_boxes": []}`, wantUp: 1, wantErrors: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.body))
+			})
+			defer server.Close()
+
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{}))
+
+			if got := counterValue(t, reg, "storagebox_exporter_unexpected_response_errors_total"); got != tt.wantErrors {
+				t.Errorf("expected %v unexpected response errors, got %v", tt.wantErrors, got)
+			}
+			if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != tt.wantUp {
+				t.Errorf("expected up=%v, got %v", tt.wantUp, got)
+			}
+			if got := counterValue(t, reg, "storagebox_exporter_network_errors_total"); got != 0 {
+				t.Errorf("expected no network errors, got %v", got)
+			}
+		})
+	}
+}
+
 type testNetworkError struct {
 	message string
 }
@@ -628,6 +788,275 @@ func TestCollectUpAndBuildInfo(t *testing.T) {
 	})
 }
 
+func TestCollectSnapshotPlanDetails(t *testing.T) {
+	tests := []struct {
+		name        string
+		plan        interface{}
+		wantMax     float64
+		wantHour    float64
+		wantWeekday float64
+	}{
+		{
+			name: "weekly plan",
+			plan: map[string]interface{}{
+				"enabled": true, "max_snapshots": 10, "minute": 30, "hour": 3, "day_of_week": 7, "day_of_month": nil,
+			},
+			wantMax: 10, wantHour: 3, wantWeekday: 7,
+		},
+		{
+			name: "daily plan",
+			plan: map[string]interface{}{
+				"enabled": true, "max_snapshots": 5, "minute": 0, "hour": 22, "day_of_week": nil, "day_of_month": nil,
+			},
+			wantMax: 5, wantHour: 22, wantWeekday: -1,
+		},
+		{name: "nil plan", plan: nil, wantMax: -1, wantHour: -1, wantWeekday: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := mockStorageBoxResponse()
+			boxes := response["storage_boxes"].([]map[string]interface{})
+			boxes[0]["snapshot_plan"] = tt.plan
+
+			reg, _ := newMockRegistry(t, response)
+			labels := map[string]string{"id": "12345", "name": "test-storagebox"}
+
+			if got := labeledGaugeValue(t, reg, "storagebox_snapshot_plan_max_snapshots", labels); got != tt.wantMax {
+				t.Errorf("expected storagebox_snapshot_plan_max_snapshots=%v, got %v", tt.wantMax, got)
+			}
+			if got := labeledGaugeValue(t, reg, "storagebox_snapshot_plan_hour", labels); got != tt.wantHour {
+				t.Errorf("expected storagebox_snapshot_plan_hour=%v, got %v", tt.wantHour, got)
+			}
+			if got := labeledGaugeValue(t, reg, "storagebox_snapshot_plan_day_of_week", labels); got != tt.wantWeekday {
+				t.Errorf("expected storagebox_snapshot_plan_day_of_week=%v, got %v", tt.wantWeekday, got)
+			}
+		})
+	}
+}
+
+func TestCollectSnapshotMetrics(t *testing.T) {
+	var snapshotCalls atomic.Int32
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/storage_boxes":
+			if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+				t.Errorf("Failed to encode mock response: %v", err)
+			}
+		case "/storage_boxes/12345/snapshots":
+			snapshotCalls.Add(1)
+			_, _ = w.Write([]byte(`{"snapshots": [
+				{"id": 1, "stats": {"size": 1024}, "created": "2025-03-01T00:00:00Z"},
+				{"id": 2, "stats": {"size": 2048}, "created": "2025-01-01T00:00:00Z"},
+				{"id": 3, "stats": {"size": 4096}, "created": "2025-02-01T00:00:00Z"}
+			]}`))
+		default:
+			// The second box was deleted after it was listed
+			snapshotCalls.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error": {"code": "not_found", "message": "storage box not found"}}`))
+		}
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, time.Minute, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshots_count", map[string]string{"id": "12345"}); got != -1 {
+		t.Errorf("expected no snapshot metrics by default, got %v", got)
+	}
+	if got := snapshotCalls.Load(); got != 0 {
+		t.Fatalf("expected no snapshot API calls by default, got %d", got)
+	}
+
+	collector.SetSnapshotMetrics(true)
+	collector.cache.Clear()
+	labels := map[string]string{"id": "12345", "name": "test-storagebox"}
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshots_count", labels); got != 3 {
+		t.Errorf("expected 3 snapshots, got %v", got)
+	}
+	oldest := float64(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Unix())
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshot_oldest_timestamp", labels); got != oldest {
+		t.Errorf("expected the oldest snapshot at %v, got %v", oldest, got)
+	}
+
+	// A 404 for one box leaves out its snapshot metrics but not the scrape
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshots_count", map[string]string{"id": "12346"}); got != -1 {
+		t.Errorf("expected no snapshot metrics for the deleted box, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_up"); got != 1 {
+		t.Errorf("expected storagebox_up 1 despite the 404, got %v", got)
+	}
+	if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_bytes", map[string]string{"id": "12346"}); got == -1 {
+		t.Error("expected the other metrics of the deleted box to remain")
+	}
+
+	// Cache hits reuse the snapshots fetched with the boxes
+	if got := snapshotCalls.Load(); got != 2 {
+		t.Errorf("expected one snapshot call per box for a single API fetch, got %d", got)
+	}
+
+	// So does a replica sharing the cache that never fetched them itself
+	replica := NewStorageBoxCollector(client, time.Minute, 0, 0, BuildInfo{})
+	replica.SetSnapshotMetrics(true)
+	replica.cache = collector.cache
+	replicaReg := prometheus.NewRegistry()
+	replicaReg.MustRegister(replica)
+	if got := labeledGaugeValue(t, replicaReg, "storagebox_snapshots_count", labels); got != 3 {
+		t.Errorf("expected 3 snapshots from the shared cache, got %v", got)
+	}
+	if got := snapshotCalls.Load(); got != 2 {
+		t.Errorf("expected no snapshot calls for a cache hit, got %d", got)
+	}
+}
+
+func TestCollectSnapshotMetricsCachedPerBox(t *testing.T) {
+	var snapshotCalls atomic.Int32
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/storage_boxes" {
+			if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+				t.Errorf("Failed to encode mock response: %v", err)
+			}
+			return
+		}
+		snapshotCalls.Add(1)
+		_, _ = w.Write([]byte(`{"snapshots": [{"id": 1, "stats": {"size": 1024}, "created": "2025-03-01T00:00:00Z"}]}`))
+	})
+	defer server.Close()
+
+	redis := miniredis.RunT(t)
+	collector := NewStorageBoxCollector(client, time.Minute, 0, 0, BuildInfo{})
+	collector.SetSnapshotMetrics(true)
+	if err := collector.SetRedisCache("redis://" + redis.Addr()); err != nil {
+		t.Fatalf("SetRedisCache() unexpected error: %v", err)
+	}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshots_count", map[string]string{"id": "12346"}); got != 1 {
+		t.Fatalf("expected 1 snapshot, got %v", got)
+	}
+	// One entry for the account and one per box, each with its own TTL
+	snapshotKey := "storagebox_exporter:" + collector.snapshotCacheKey(12346)
+	if got := len(redis.Keys()); got != 3 {
+		t.Errorf("expected the storage boxes and two snapshot summaries cached, got keys %v", redis.Keys())
+	}
+	if got := redis.TTL(snapshotKey); got != time.Minute {
+		t.Errorf("expected the snapshot summary to expire after the cache TTL, got %v", got)
+	}
+
+	// A box whose summary expired only lacks the snapshot metrics until the
+	// next fetch
+	redis.Del(snapshotKey)
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshots_count", map[string]string{"id": "12346"}); got != -1 {
+		t.Errorf("expected no snapshot metrics for the box without a cached summary, got %v", got)
+	}
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshots_count", map[string]string{"id": "12345"}); got != 1 {
+		t.Errorf("expected the cached summary of the other box, got %v", got)
+	}
+	if got := snapshotCalls.Load(); got != 2 {
+		t.Errorf("expected cache hits not to list snapshots, got %d listings", got)
+	}
+}
+
+func TestCollectSnapshotRequestStats(t *testing.T) {
+	boxes, err := json.Marshal(mockStorageBoxResponse())
+	if err != nil {
+		t.Fatalf("Failed to encode mock response: %v", err)
+	}
+	snapshots := []byte(`{"snapshots": [{"id": 1, "stats": {"size": 1024}, "created": "2025-03-01T00:00:00Z"}]}`)
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/storage_boxes" {
+			_, _ = w.Write(boxes)
+			return
+		}
+		w.Header().Set("Deprecation", "@1767225599")
+		_, _ = w.Write(snapshots)
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, time.Minute, 0, 0, BuildInfo{})
+	collector.SetSnapshotMetrics(true)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	want := float64(len(boxes) + 2*len(snapshots))
+	if got := gaugeValue(t, reg, "storagebox_exporter_api_payload_bytes"); got != want {
+		t.Errorf("expected the payload of the boxes and both snapshot listings, %v bytes, got %v", want, got)
+	}
+	// Cache hits keep the deprecation signal of the last fetch
+	if got := gaugeValue(t, reg, "storagebox_exporter_api_deprecated"); got != 1 {
+		t.Errorf("expected the deprecation header of a snapshot listing to be recorded, got %v", got)
+	}
+}
+
+func TestCollectSnapshotMetricsErrors(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	response := mockStorageBoxResponse()
+	boxes := response["storage_boxes"].([]map[string]interface{})
+	for id := 20000; id < 20010; id++ {
+		box := make(map[string]interface{}, len(boxes[0]))
+		for k, v := range boxes[0] {
+			box[k] = v
+		}
+		box["id"] = id
+		boxes = append(boxes, box)
+	}
+	response["storage_boxes"] = boxes
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/storage_boxes":
+			if err := json.NewEncoder(w).Encode(response); err != nil {
+				t.Errorf("Failed to encode mock response: %v", err)
+			}
+		case "/storage_boxes/12346/snapshots":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error": {"code": "server_error", "message": "internal error"}}`))
+		default:
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				previous := maxInFlight.Load()
+				if n <= previous || maxInFlight.CompareAndSwap(previous, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"snapshots": []}`))
+		}
+	})
+	defer server.Close()
+
+	client.SetMaxAttempts(1)
+	// Cached so that only the first scrape calls the API
+	collector := NewStorageBoxCollector(client, time.Minute, 0, 0, BuildInfo{})
+	collector.SetSnapshotMetrics(true)
+	collector.SetDetailConcurrency(1)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshots_count", map[string]string{"id": "12345"}); got != 0 {
+		t.Errorf("expected 0 snapshots, got %v", got)
+	}
+	if got := counterValue(t, reg, "storagebox_exporter_server_errors_total"); got != 1 {
+		t.Errorf("expected the failed snapshot listing in server_errors_total, got %v", got)
+	}
+	if got := counterValue(t, reg, "storagebox_exporter_scrape_errors_total"); got != 1 {
+		t.Errorf("expected the failed snapshot listing in scrape_errors_total, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_up"); got != 1 {
+		t.Errorf("expected storagebox_up 1 despite the failed snapshot listing, got %v", got)
+	}
+	if got := maxInFlight.Load(); got > 1 {
+		t.Errorf("expected snapshot listings one at a time, got %d at once", got)
+	}
+}
+
 func TestCollectWithNilSnapshotPlan(t *testing.T) {
 	response := map[string]interface{}{
 		"storage_boxes": []map[string]interface{}{
@@ -698,3 +1127,1774 @@ func TestCollectWithNilSnapshotPlan(t *testing.T) {
 		t.Errorf("expected at least 10 metrics, got %d", len(metrics))
 	}
 }
+
+// counterValue gathers metrics from the registry and returns the value of the
+// named counter, or -1 if the family is absent.
+func counterValue(t *testing.T, reg *prometheus.Registry, name string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() == name {
+			return mf.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	return -1
+}
+
+// labeledGaugeValue gathers metrics from the registry and returns the value of
+// the named metric family's sample whose labels include all of the given
+// label pairs, or -1 if no such sample exists.
+func labeledGaugeValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			matched := 0
+			for _, lp := range m.GetLabel() {
+				if v, ok := labels[lp.GetName()]; ok && v == lp.GetValue() {
+					matched++
+				}
+			}
+			if matched == len(labels) {
+				return m.GetGauge().GetValue()
+			}
+		}
+	}
+	return -1
+}
+
+// labeledCounterValue is like labeledGaugeValue for counters
+func labeledCounterValue(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			matched := 0
+			for _, lp := range m.GetLabel() {
+				if v, ok := labels[lp.GetName()]; ok && v == lp.GetValue() {
+					matched++
+				}
+			}
+			if matched == len(labels) {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return -1
+}
+
+// newMockRegistry registers a collector backed by a mock server serving the
+// given response and returns the registry. The server is closed on cleanup.
+func newMockRegistry(t *testing.T, response interface{}) (*prometheus.Registry, *StorageBoxCollector) {
+	t.Helper()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	}
+	server, client := setupMockServer(t, handler)
+	t.Cleanup(server.Close)
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+	return reg, collector
+}
+
+func TestCollectTypeBoxCount(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	for _, boxType := range []string{"BX10", "BX20"} {
+		if got := labeledGaugeValue(t, reg, "storagebox_type_box_count", map[string]string{"type": boxType}); got != 1 {
+			t.Errorf("expected storagebox_type_box_count{type=%q}=1, got %v", boxType, got)
+		}
+	}
+}
+
+func TestCollectSnapshotPlanConfigured(t *testing.T) {
+	tests := []struct {
+		name           string
+		plan           interface{}
+		wantEnabled    float64
+		wantConfigured float64
+	}{
+		{name: "nil plan", plan: nil, wantEnabled: 0, wantConfigured: 0},
+		{name: "disabled plan", plan: map[string]interface{}{"enabled": false}, wantEnabled: 0, wantConfigured: 1},
+		{name: "enabled plan", plan: map[string]interface{}{"enabled": true}, wantEnabled: 1, wantConfigured: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := mockStorageBoxResponse()
+			boxes := response["storage_boxes"].([]map[string]interface{})
+			boxes[0]["snapshot_plan"] = tt.plan
+
+			reg, _ := newMockRegistry(t, response)
+			labels := map[string]string{"id": "12345"}
+
+			if got := labeledGaugeValue(t, reg, "storagebox_snapshot_plan_enabled", labels); got != tt.wantEnabled {
+				t.Errorf("expected storagebox_snapshot_plan_enabled=%v, got %v", tt.wantEnabled, got)
+			}
+			if got := labeledGaugeValue(t, reg, "storagebox_snapshot_plan_configured", labels); got != tt.wantConfigured {
+				t.Errorf("expected storagebox_snapshot_plan_configured=%v, got %v", tt.wantConfigured, got)
+			}
+		})
+	}
+}
+
+func TestCollectAccessExternalMismatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		reachable bool
+		want      float64
+	}{
+		{name: "protocols enabled and reachable", reachable: true, want: 0},
+		{name: "protocols enabled but not reachable", reachable: false, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := mockStorageBoxResponse()
+			boxes := response["storage_boxes"].([]map[string]interface{})
+			boxes[0]["access_settings"].(map[string]interface{})["reachable_externally"] = tt.reachable
+
+			reg, _ := newMockRegistry(t, response)
+
+			if got := labeledGaugeValue(t, reg, "storagebox_access_external_mismatch", map[string]string{"id": "12345"}); got != tt.want {
+				t.Errorf("expected storagebox_access_external_mismatch=%v, got %v", tt.want, got)
+			}
+			// The second mock box has no protocols enabled, so it never mismatches.
+			if got := labeledGaugeValue(t, reg, "storagebox_access_external_mismatch", map[string]string{"id": "12346"}); got != 0 {
+				t.Errorf("expected storagebox_access_external_mismatch=0 for box without protocols, got %v", got)
+			}
+		})
+	}
+}
+
+func TestCollectDuplicateNames(t *testing.T) {
+	response := mockStorageBoxResponse()
+	boxes := response["storage_boxes"].([]map[string]interface{})
+	boxes[1]["name"] = boxes[0]["name"]
+
+	reg, _ := newMockRegistry(t, response)
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	// The duplicate is counted and logged once, not on every scrape
+	for range 3 {
+		if got := counterValue(t, reg, "storagebox_exporter_duplicate_names_total"); got != 1 {
+			t.Errorf("expected storagebox_exporter_duplicate_names_total=1, got %v", got)
+		}
+	}
+	if got := strings.Count(buf.String(), "share the same name"); got != 1 {
+		t.Errorf("expected the duplicate name to be logged once, got %d warnings", got)
+	}
+
+	// Both boxes must remain distinguishable through the id label.
+	for _, id := range []string{"12345", "12346"} {
+		labels := map[string]string{"id": id, "name": "test-storagebox"}
+		for _, metric := range []string{
+			"storagebox_disk_usage_bytes",
+			"storagebox_access_ssh_enabled",
+			"storagebox_snapshot_plan_enabled",
+			"storagebox_protection_delete",
+		} {
+			if got := labeledGaugeValue(t, reg, metric, labels); got == -1 {
+				t.Errorf("expected %s for box %s", metric, id)
+			}
+		}
+	}
+}
+
+func TestCollectUniqueNames(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	if got := counterValue(t, reg, "storagebox_exporter_duplicate_names_total"); got != 0 {
+		t.Errorf("expected storagebox_exporter_duplicate_names_total=0, got %v", got)
+	}
+}
+
+func TestCollectSharedServers(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+	if got := counterValue(t, reg, "storagebox_exporter_shared_server_total"); got != 0 {
+		t.Errorf("expected storagebox_exporter_shared_server_total=0 for distinct servers, got %v", got)
+	}
+
+	response := mockStorageBoxResponse()
+	boxes := response["storage_boxes"].([]map[string]interface{})
+	boxes[1]["server"] = boxes[0]["server"]
+
+	reg, _ = newMockRegistry(t, response)
+	for range 3 {
+		if got := counterValue(t, reg, "storagebox_exporter_shared_server_total"); got != 1 {
+			t.Errorf("expected storagebox_exporter_shared_server_total=1 across scrapes, got %v", got)
+		}
+	}
+	// Both boxes keep their own series through the id label
+	for _, id := range []string{"12345", "12346"} {
+		labels := map[string]string{"id": id, "server": "u123456.your-storagebox.de"}
+		if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_bytes", labels); got == -1 {
+			t.Errorf("expected storagebox_disk_usage_bytes for box %s", id)
+		}
+	}
+}
+
+func TestOutcomeWindow(t *testing.T) {
+	w := newOutcomeWindow(4)
+	if _, ok := w.ratio(); ok {
+		t.Error("expected no ratio before any outcome is recorded")
+	}
+
+	steps := []struct {
+		success bool
+		want    float64
+	}{
+		{true, 1},
+		{false, 0.5},
+		{true, 2.0 / 3.0},
+		{false, 0.5},
+		// Window is full: each new outcome evicts the oldest one.
+		{false, 0.25}, // evicts true
+		{false, 0.25}, // evicts false
+		{false, 0},    // evicts true
+	}
+	for i, step := range steps {
+		w.record(step.success)
+		got, ok := w.ratio()
+		if !ok || got != step.want {
+			t.Errorf("step %d: expected ratio %v, got %v (ok=%v)", i, step.want, got, ok)
+		}
+	}
+}
+
+func TestCollectAPISuccessRatio(t *testing.T) {
+	var fail atomic.Bool
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	}
+	server, client := setupMockServer(t, handler)
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	collector.SetSuccessWindow(4)
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	// Each gather performs one API call: 3 successes followed by 1 failure.
+	for i := 0; i < 3; i++ {
+		if _, err := reg.Gather(); err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+	}
+	fail.Store(true)
+	if got := gaugeValue(t, reg, "storagebox_exporter_api_success_ratio"); got != 0.75 {
+		t.Errorf("expected storagebox_exporter_api_success_ratio=0.75, got %v", got)
+	}
+}
+
+func TestCollectUsageCounter(t *testing.T) {
+	var usage atomic.Int64
+	usage.Store(500)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		response := mockStorageBoxResponse()
+		boxes := response["storage_boxes"].([]map[string]interface{})
+		boxes[0]["stats"].(map[string]interface{})["size"] = usage.Load()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	}
+	server, client := setupMockServer(t, handler)
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	collector.SetUsageCounter(true)
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	counter := func() float64 {
+		t.Helper()
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+		for _, mf := range families {
+			if mf.GetName() != "storagebox_disk_usage_bytes_total" {
+				continue
+			}
+			for _, m := range mf.GetMetric() {
+				for _, lp := range m.GetLabel() {
+					if lp.GetName() == "id" && lp.GetValue() == "12345" {
+						return m.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+		return -1
+	}
+
+	for _, step := range []struct {
+		usage int64
+		want  float64
+	}{
+		{usage: 500, want: 500},
+		{usage: 800, want: 800},
+		{usage: 300, want: 800}, // usage decreased, counter stays at the peak
+		{usage: 900, want: 900},
+	} {
+		usage.Store(step.usage)
+		if got := counter(); got != step.want {
+			t.Errorf("usage %d: expected storagebox_disk_usage_bytes_total=%v, got %v", step.usage, step.want, got)
+		}
+	}
+}
+
+func TestCollectUsageDropRatio(t *testing.T) {
+	var usage atomic.Int64
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		response := mockStorageBoxResponse()
+		boxes := response["storage_boxes"].([]map[string]interface{})
+		boxes[0]["stats"].(map[string]interface{})["size"] = usage.Load()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	}
+	server, client := setupMockServer(t, handler)
+	defer server.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{}))
+
+	for _, step := range []struct {
+		usage int64
+		want  float64
+	}{
+		{usage: 1000, want: 0},  // first scrape has nothing to compare with
+		{usage: 500, want: 0.5}, // usage halved
+		{usage: 500, want: 0},   // stable
+		{usage: 800, want: 0},   // increasing
+	} {
+		usage.Store(step.usage)
+		if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_drop_ratio", map[string]string{"id": "12345"}); got != step.want {
+			t.Errorf("usage %d: expected drop ratio %v, got %v", step.usage, step.want, got)
+		}
+	}
+}
+
+func TestCollectUsageDropRatioCached(t *testing.T) {
+	var usage atomic.Int64
+	var calls atomic.Int32
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		response := mockStorageBoxResponse()
+		boxes := response["storage_boxes"].([]map[string]interface{})
+		boxes[0]["stats"].(map[string]interface{})["size"] = usage.Load()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, time.Minute, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+	labels := map[string]string{"id": "12345"}
+
+	usage.Store(1000)
+	if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_drop_ratio", labels); got != 0 {
+		t.Errorf("expected no drop on the first scrape, got %v", got)
+	}
+	usage.Store(500)
+	collector.cache.Clear()
+	if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_drop_ratio", labels); got != 0.5 {
+		t.Errorf("expected a drop of 0.5 after usage halved, got %v", got)
+	}
+
+	// Cached scrapes carry no new data and keep the last computed drop
+	for range 2 {
+		if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_drop_ratio", labels); got != 0.5 {
+			t.Errorf("expected cached scrapes to keep the drop of 0.5, got %v", got)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected the cached scrapes not to call the API, got %d calls", got)
+	}
+}
+
+func TestCollectUsageCounterDisabledByDefault(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_bytes_total", map[string]string{"id": "12345"}); got != -1 {
+		t.Errorf("expected no storagebox_disk_usage_bytes_total by default, got %v", got)
+	}
+}
+
+func TestCollectPaused(t *testing.T) {
+	var calls atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	}
+	server, client := setupMockServer(t, handler)
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	// Prime the last-known data with one live scrape.
+	if got := gaugeValue(t, reg, "storagebox_exporter_paused"); got != 0 {
+		t.Errorf("expected storagebox_exporter_paused=0, got %v", got)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 API call, got %d", calls.Load())
+	}
+
+	collector.SetPaused(true)
+	for i := 0; i < 3; i++ {
+		if got := gaugeValue(t, reg, "storagebox_exporter_paused"); got != 1 {
+			t.Errorf("expected storagebox_exporter_paused=1, got %v", got)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected no API calls while paused, got %d total", calls.Load())
+	}
+	if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_bytes", map[string]string{"id": "12345"}); got != 536870912000 {
+		t.Errorf("expected last-known storagebox_disk_usage_bytes while paused, got %v", got)
+	}
+
+	collector.SetPaused(false)
+	if got := gaugeValue(t, reg, "storagebox_exporter_paused"); got != 0 {
+		t.Errorf("expected storagebox_exporter_paused=0 after resume, got %v", got)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected API calls to resume, got %d total", calls.Load())
+	}
+}
+
+func TestCollectMinScrapeInterval(t *testing.T) {
+	var calls atomic.Int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	}
+	server, client := setupMockServer(t, handler)
+	defer server.Close()
+
+	// Cache disabled: only the minimum interval guard prevents API calls
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	collector.SetMinScrapeInterval(time.Minute)
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_bytes", map[string]string{"id": "12345"}); got != 536870912000 {
+			t.Errorf("scrape %d: expected storagebox_disk_usage_bytes=536870912000, got %v", i, got)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected the second scrape to reuse the prior result, got %d API calls", calls.Load())
+	}
+
+	collector.SetMinScrapeInterval(0)
+	gaugeValue(t, reg, "storagebox_exporter_up")
+	if calls.Load() != 2 {
+		t.Errorf("expected an API call once the guard is disabled, got %d total", calls.Load())
+	}
+}
+
+func TestCollectPausedWithoutData(t *testing.T) {
+	var calls atomic.Int32
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	collector.SetPaused(true)
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != 0 {
+		t.Errorf("expected storagebox_exporter_up=0 without last-known data, got %v", got)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("expected no API calls while paused, got %d", calls.Load())
+	}
+}
+
+func TestCollectLastScrapeRetries(t *testing.T) {
+	var requests atomic.Int32
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// Fail only the first request, forcing a single retry
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+	client.SetMaxAttempts(2)
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	if got := gaugeValue(t, reg, "storagebox_exporter_last_scrape_retries"); got != 1 {
+		t.Errorf("expected 1 retry in the first scrape, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != 1 {
+		t.Errorf("expected the retried scrape to succeed, got up=%v", got)
+	}
+
+	// The gauge is reset on every scrape
+	if got := gaugeValue(t, reg, "storagebox_exporter_last_scrape_retries"); got != 0 {
+		t.Errorf("expected 0 retries in the second scrape, got %v", got)
+	}
+}
+
+func TestCollectAccessCounts(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	tests := []struct {
+		metric string
+		want   float64
+	}{
+		{metric: "storagebox_access_ssh_enabled_count", want: 1},
+		{metric: "storagebox_access_samba_enabled_count", want: 1},
+		{metric: "storagebox_access_webdav_enabled_count", want: 0},
+		{metric: "storagebox_access_zfs_enabled_count", want: 0},
+	}
+	for _, tt := range tests {
+		if got := gaugeValue(t, reg, tt.metric); got != tt.want {
+			t.Errorf("expected %s %v, got %v", tt.metric, tt.want, got)
+		}
+	}
+}
+
+func TestCollectSnapshotUsageRatio(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	// 100GB of snapshots out of 500GB total usage
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshot_usage_ratio", map[string]string{"id": "12345"}); got != 0.2 {
+		t.Errorf("expected snapshot usage ratio 0.2 for the active box, got %v", got)
+	}
+	// No usage at all must not divide by zero
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshot_usage_ratio", map[string]string{"id": "12346"}); got != 0 {
+		t.Errorf("expected snapshot usage ratio 0 for the empty box, got %v", got)
+	}
+}
+
+func TestCollectDiskUsageRatio(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	// 500GB used of the 1TB BX10 quota
+	got := labeledGaugeValue(t, reg, "storagebox_disk_usage_ratio", map[string]string{"id": "12345", "server": "u123456.your-storagebox.de", "location": "fsn1"})
+	if math.Abs(got-0.488) > 0.001 {
+		t.Errorf("expected disk usage ratio ~0.488 for the BX10 box, got %v", got)
+	}
+	if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_ratio", map[string]string{"id": "12346"}); got != 0 {
+		t.Errorf("expected disk usage ratio 0 for the empty box, got %v", got)
+	}
+
+	// An unknown quota must not divide by zero
+	reg, _ = newMockRegistry(t, map[string]interface{}{
+		"storage_boxes": []map[string]interface{}{
+			{"id": 1, "name": "box", "stats": map[string]interface{}{"size": 1024}},
+		},
+	})
+	if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_ratio", map[string]string{"id": "1"}); got != 0 {
+		t.Errorf("expected disk usage ratio 0 without a quota, got %v", got)
+	}
+}
+
+func TestCollectSnapshotToDataRatio(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	// 100GB of snapshots for 400GB of file data
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshot_to_data_ratio", map[string]string{"id": "12345"}); got != 0.25 {
+		t.Errorf("expected snapshot to data ratio 0.25 for the active box, got %v", got)
+	}
+	// No file data must not divide by zero
+	if got := labeledGaugeValue(t, reg, "storagebox_snapshot_to_data_ratio", map[string]string{"id": "12346"}); got != 0 {
+		t.Errorf("expected snapshot to data ratio 0 for the empty box, got %v", got)
+	}
+}
+
+func TestCollectEmptyAccount(t *testing.T) {
+	reg, _ := newMockRegistry(t, map[string]interface{}{
+		"storage_boxes": []interface{}{},
+	})
+
+	if got := gaugeValue(t, reg, "storagebox_exporter_boxes_total"); got != 0 {
+		t.Errorf("expected storagebox_exporter_boxes_total 0, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_up"); got != 1 {
+		t.Errorf("expected storagebox_up 1 for a successful empty fetch, got %v", got)
+	}
+}
+
+func TestCollectStorageBoxUpOnError(t *testing.T) {
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer server.Close()
+
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	if got := gaugeValue(t, reg, "storagebox_up"); got != 0 {
+		t.Errorf("expected storagebox_up 0 on a failed fetch, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_boxes_total"); got != -1 {
+		t.Errorf("expected no storagebox_exporter_boxes_total on a failed fetch, got %v", got)
+	}
+}
+
+func TestLastScrapeSuccessTimestamp(t *testing.T) {
+	var fail atomic.Bool
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{}))
+
+	// Not exposed before the first successful scrape
+	fail.Store(true)
+	if got := gaugeValue(t, reg, "storagebox_exporter_last_scrape_success_timestamp_seconds"); got != -1 {
+		t.Errorf("expected no last success timestamp before a successful scrape, got %v", got)
+	}
+
+	fail.Store(false)
+	before := float64(time.Now().UnixNano()) / 1e9
+	success := gaugeValue(t, reg, "storagebox_exporter_last_scrape_success_timestamp_seconds")
+	if success < before || success > float64(time.Now().UnixNano())/1e9 {
+		t.Fatalf("expected the time of the successful scrape, got %v", success)
+	}
+
+	// A failing scrape reports up=0 and keeps the last success timestamp
+	fail.Store(true)
+	if got := gaugeValue(t, reg, "storagebox_up"); got != 0 {
+		t.Errorf("expected storagebox_up 0 on a failed fetch, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_last_scrape_success_timestamp_seconds"); got != success {
+		t.Errorf("expected the last success timestamp %v to be kept on failure, got %v", success, got)
+	}
+}
+
+func TestCollectAccessPerProtocol(t *testing.T) {
+	reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+
+	if got := labeledGaugeValue(t, reg, "storagebox_access", map[string]string{"id": "12345"}); got != -1 {
+		t.Errorf("expected no storagebox_access by default, got %v", got)
+	}
+	collector.SetAccessProtocolMetric(true)
+
+	tests := []struct {
+		id       string
+		protocol string
+		want     float64
+	}{
+		{id: "12345", protocol: "ssh", want: 1},
+		{id: "12345", protocol: "samba", want: 1},
+		{id: "12345", protocol: "webdav", want: 0},
+		{id: "12345", protocol: "zfs", want: 0},
+		{id: "12346", protocol: "ssh", want: 0},
+		{id: "12346", protocol: "samba", want: 0},
+	}
+
+	for _, tt := range tests {
+		labels := map[string]string{"id": tt.id, "protocol": tt.protocol}
+		if got := labeledGaugeValue(t, reg, "storagebox_access", labels); got != tt.want {
+			t.Errorf("storagebox_access%v = %v, want %v", labels, got, tt.want)
+		}
+	}
+
+	// The per-protocol metric names are kept for compatibility
+	if got := labeledGaugeValue(t, reg, "storagebox_access_ssh_enabled", map[string]string{"id": "12345"}); got != 1 {
+		t.Errorf("expected storagebox_access_ssh_enabled 1, got %v", got)
+	}
+}
+
+// infoLabels returns the labels of the storagebox_info sample for the given id
+func infoLabels(t *testing.T, reg *prometheus.Registry, id string) map[string]string {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != "storagebox_info" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := make(map[string]string)
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			if labels["id"] == id {
+				return labels
+			}
+		}
+	}
+	t.Fatalf("storagebox_info for id %s not found", id)
+	return nil
+}
+
+func TestCollectInfoCreatedLabel(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	}
+	server, client := setupMockServer(t, handler)
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	collector.SetCreatedLabel(true)
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	if got := infoLabels(t, reg, "12345")["created"]; got != "2024-01-15T10:30:00Z" {
+		t.Errorf("expected created label 2024-01-15T10:30:00Z, got %q", got)
+	}
+}
+
+func TestCollectInfoCreatedLabelDisabledByDefault(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	if got, ok := infoLabels(t, reg, "12345")["created"]; ok {
+		t.Errorf("expected no created label by default, got %q", got)
+	}
+}
+
+func TestCollectQuotaDistribution(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{name: "storagebox_disk_quota_bytes_min", want: 1099511627776},   // 1TB
+		{name: "storagebox_disk_quota_bytes_max", want: 2199023255552},   // 2TB
+		{name: "storagebox_disk_quota_bytes_avg", want: 1649267441664.0}, // 1.5TB
+	}
+	for _, tt := range tests {
+		if got := gaugeValue(t, reg, tt.name); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollectQuotaDistributionEmptyAccount(t *testing.T) {
+	reg, _ := newMockRegistry(t, map[string]interface{}{
+		"storage_boxes": []interface{}{},
+	})
+
+	if got := gaugeValue(t, reg, "storagebox_disk_quota_bytes_avg"); got != -1 {
+		t.Errorf("expected no quota distribution for an empty account, got %v", got)
+	}
+}
+
+func TestCollectAccountTotals(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	if got := gaugeValue(t, reg, "storagebox_account_total_quota_bytes"); got != 3298534883328 { // 3TB
+		t.Errorf("storagebox_account_total_quota_bytes = %v, want 3298534883328", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_account_total_usage_bytes"); got != 536870912000 { // 500GB
+		t.Errorf("storagebox_account_total_usage_bytes = %v, want 536870912000", got)
+	}
+}
+
+func TestCollectAccountTotalsEmptyAccount(t *testing.T) {
+	reg, _ := newMockRegistry(t, map[string]interface{}{
+		"storage_boxes": []interface{}{},
+	})
+
+	if got := gaugeValue(t, reg, "storagebox_account_total_quota_bytes"); got != 0 {
+		t.Errorf("expected a zero total quota for an empty account, got %v", got)
+	}
+}
+
+func TestCollectSkipInactive(t *testing.T) {
+	tests := []struct {
+		name         string
+		skipInactive bool
+		wantInactive bool
+	}{
+		{name: "includes all boxes by default", skipInactive: false, wantInactive: true},
+		{name: "skips inactive boxes", skipInactive: true, wantInactive: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+			collector.SetSkipInactive(tt.skipInactive)
+
+			inactive := labeledGaugeValue(t, reg, "storagebox_disk_quota_bytes", map[string]string{"id": "12346"})
+			if (inactive != -1) != tt.wantInactive {
+				t.Errorf("expected inactive box metrics present=%v, got value %v", tt.wantInactive, inactive)
+			}
+			if got := labeledGaugeValue(t, reg, "storagebox_disk_quota_bytes", map[string]string{"id": "12345"}); got == -1 {
+				t.Error("expected metrics for the active box")
+			}
+
+			// Skipped boxes still count in the summary
+			if got := labeledGaugeValue(t, reg, "storagebox_status_box_count", map[string]string{"status": "inactive"}); got != 1 {
+				t.Errorf("expected 1 inactive box in the summary, got %v", got)
+			}
+			if got := gaugeValue(t, reg, "storagebox_exporter_boxes_total"); got != 2 {
+				t.Errorf("expected 2 boxes in total, got %v", got)
+			}
+		})
+	}
+}
+
+func TestGoroutinesDelta(t *testing.T) {
+	collector := NewStorageBoxCollector(hetzner.NewClient("test-token"), 0, 0, 0, BuildInfo{})
+
+	steps := []struct {
+		current int
+		want    int
+	}{
+		{current: 10, want: 0}, // first scrape has no previous value
+		{current: 15, want: 5},
+		{current: 15, want: 0},
+		{current: 12, want: -3},
+	}
+	for i, step := range steps {
+		if got := collector.goroutinesDelta(step.current); got != step.want {
+			t.Errorf("step %d: goroutinesDelta(%d) = %d, want %d", i, step.current, got, step.want)
+		}
+	}
+}
+
+func TestCollectAPITimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		wantUp  float64
+	}{
+		{name: "timeout shorter than API latency fires", timeout: 20 * time.Millisecond, wantUp: 0},
+		{name: "timeout longer than API latency succeeds", timeout: 5 * time.Second, wantUp: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(200 * time.Millisecond):
+				case <-r.Context().Done():
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(mockStorageBoxResponse())
+			})
+			defer server.Close()
+
+			collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+			collector.SetAPITimeout(tt.timeout)
+			reg := prometheus.NewRegistry()
+			if err := reg.Register(collector); err != nil {
+				t.Fatalf("failed to register collector: %v", err)
+			}
+
+			if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != tt.wantUp {
+				t.Errorf("expected up=%v, got %v", tt.wantUp, got)
+			}
+		})
+	}
+}
+
+func TestCollectDaysSinceCreated(t *testing.T) {
+	response := mockStorageBoxResponse()
+	boxes := response["storage_boxes"].([]map[string]interface{})
+	boxes[1]["created"] = time.Now().Add(48 * time.Hour).Format(time.RFC3339) // invalid: in the future
+
+	reg, _ := newMockRegistry(t, response)
+
+	created := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	want := float64(int64(time.Since(created) / (24 * time.Hour)))
+	got := labeledGaugeValue(t, reg, "storagebox_days_since_created", map[string]string{"id": "12345"})
+	if got < 365 || got < want-1 || got > want {
+		t.Errorf("expected about %v days since creation, got %v", want, got)
+	}
+
+	if got := labeledGaugeValue(t, reg, "storagebox_days_since_created", map[string]string{"id": "12346"}); got != -1 {
+		t.Errorf("expected no metric for a future creation time, got %v", got)
+	}
+}
+
+func TestCollectAPIPayloadBytes(t *testing.T) {
+	body, err := json.Marshal(mockStorageBoxResponse())
+	if err != nil {
+		t.Fatalf("failed to marshal mock response: %v", err)
+	}
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, time.Minute, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	if got := gaugeValue(t, reg, "storagebox_exporter_api_payload_bytes"); got != float64(len(body)) {
+		t.Errorf("expected payload of %d bytes, got %v", len(body), got)
+	}
+	// Served from the cache without reading a response body
+	if got := gaugeValue(t, reg, "storagebox_exporter_api_payload_bytes"); got != 0 {
+		t.Errorf("expected payload of 0 bytes on a cache hit, got %v", got)
+	}
+}
+
+// failingMiddlePageHandler serves three pages of one storage box each, failing
+// the second page with a server error
+func failingMiddlePageHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "2" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		id, _ := strconv.Atoi(page)
+		var next interface{}
+		if id < 3 {
+			next = id + 1
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"storage_boxes": []map[string]interface{}{{"id": id, "name": "box-" + page, "status": "active"}},
+			"meta": map[string]interface{}{
+				"pagination": map[string]interface{}{"page": id, "per_page": 1, "next_page": next, "last_page": 3},
+			},
+		}); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	}
+}
+
+func TestCollectPaginationOnError(t *testing.T) {
+	tests := []struct {
+		name        string
+		partial     bool
+		wantUp      float64
+		wantPartial float64
+		wantBoxes   []string
+	}{
+		{name: "fail", partial: false, wantUp: 0, wantPartial: 0},
+		{name: "partial", partial: true, wantUp: 1, wantPartial: 1, wantBoxes: []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := setupMockServer(t, failingMiddlePageHandler(t))
+			defer server.Close()
+			client.SetPartialPagination(tt.partial)
+
+			collector := NewStorageBoxCollector(client, time.Minute, 0, 0, BuildInfo{})
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(collector)
+
+			if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != tt.wantUp {
+				t.Errorf("expected up=%v, got %v", tt.wantUp, got)
+			}
+			if got := gaugeValue(t, reg, "storagebox_exporter_partial_scrape"); got != tt.wantPartial {
+				t.Errorf("expected partial_scrape=%v, got %v", tt.wantPartial, got)
+			}
+			for _, id := range tt.wantBoxes {
+				if got := labeledGaugeValue(t, reg, "storagebox_status", map[string]string{"id": id}); got == -1 {
+					t.Errorf("expected metrics for box %s", id)
+				}
+			}
+			if got := counterValue(t, reg, "storagebox_exporter_cache_hits_total"); got != 0 {
+				t.Errorf("expected partial data not to be cached, got %v cache hits", got)
+			}
+		})
+	}
+}
+
+func TestTokenGeneration(t *testing.T) {
+	reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+
+	if got := gaugeValue(t, reg, "storagebox_exporter_token_generation"); got != 0 {
+		t.Errorf("expected token generation 0 for the initial token, got %v", got)
+	}
+
+	collector.client.SetToken("rotated-token")
+	if got := gaugeValue(t, reg, "storagebox_exporter_token_generation"); got != 1 {
+		t.Errorf("expected token generation 1 after a reload, got %v", got)
+	}
+
+	collector.client.SetToken("rotated-again")
+	if got := gaugeValue(t, reg, "storagebox_exporter_token_generation"); got != 2 {
+		t.Errorf("expected token generation 2 after a second reload, got %v", got)
+	}
+}
+
+func TestCollectSuccessStatusCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		codes  []int
+		wantUp float64
+	}{
+		{name: "default rejects 203", codes: nil, wantUp: 0},
+		{name: "203 accepted when configured", codes: []int{200, 203}, wantUp: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNonAuthoritativeInfo)
+				if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+					t.Errorf("Failed to encode mock response: %v", err)
+				}
+			})
+			defer server.Close()
+			client.SetSuccessStatusCodes(tt.codes)
+
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{}))
+
+			if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != tt.wantUp {
+				t.Errorf("expected up=%v, got %v", tt.wantUp, got)
+			}
+			if tt.wantUp == 1 {
+				if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_bytes", map[string]string{"id": "12345"}); got <= 0 {
+					t.Errorf("expected disk usage for box 12345, got %v", got)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	for _, source := range []string{"env", "flag", "file", "config_file"} {
+		t.Run(source, func(t *testing.T) {
+			reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+			collector.SetTokenSource(source)
+
+			if got := labeledGaugeValue(t, reg, "storagebox_exporter_token_source", map[string]string{"source": source}); got != 1 {
+				t.Errorf("expected token_source{source=%q} = 1, got %v", source, got)
+			}
+		})
+	}
+}
+
+func TestTokenSourceUnset(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	if got := gaugeValue(t, reg, "storagebox_exporter_token_source"); got != -1 {
+		t.Errorf("expected no token source metric when unset, got %v", got)
+	}
+}
+
+func TestCollectSizeUnit(t *testing.T) {
+	// Box 12345 uses 500 GiB of a 1 TiB quota in the mock response
+	tests := []struct {
+		unit      string
+		round     bool
+		wantUsage float64
+		wantQuota float64
+	}{
+		{unit: "bytes", wantUsage: 536870912000, wantQuota: 1099511627776},
+		{unit: "kib", wantUsage: 524288000, wantQuota: 1073741824},
+		{unit: "mib", wantUsage: 512000, wantQuota: 1048576},
+		{unit: "gib", wantUsage: 500, wantQuota: 1024},
+		{unit: "gib", round: true, wantUsage: 500, wantQuota: 1024},
+		{unit: "unknown", wantUsage: 536870912000, wantQuota: 1099511627776},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s round=%v", tt.unit, tt.round), func(t *testing.T) {
+			reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+			collector.SetSizeUnit(tt.unit, tt.round)
+
+			labels := map[string]string{"id": "12345"}
+			if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_bytes", labels); got != tt.wantUsage {
+				t.Errorf("expected storagebox_disk_usage_bytes=%v, got %v", tt.wantUsage, got)
+			}
+			if got := labeledGaugeValue(t, reg, "storagebox_disk_quota_bytes", labels); got != tt.wantQuota {
+				t.Errorf("expected storagebox_disk_quota_bytes=%v, got %v", tt.wantQuota, got)
+			}
+		})
+	}
+}
+
+func TestCollectSizeRound(t *testing.T) {
+	response := mockStorageBoxResponse()
+	stats := response["storage_boxes"].([]map[string]interface{})[0]["stats"].(map[string]interface{})
+	stats["size_data"] = int64(1536 << 20)      // 1.5 GiB
+	stats["size_snapshots"] = int64(1280 << 20) // 1.25 GiB
+
+	tests := []struct {
+		round         bool
+		wantData      float64
+		wantSnapshots float64
+	}{
+		{round: false, wantData: 1.5, wantSnapshots: 1.25},
+		{round: true, wantData: 2, wantSnapshots: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("round=%v", tt.round), func(t *testing.T) {
+			reg, collector := newMockRegistry(t, response)
+			collector.SetSizeUnit("gib", tt.round)
+
+			labels := map[string]string{"id": "12345"}
+			if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_data_bytes", labels); got != tt.wantData {
+				t.Errorf("expected storagebox_disk_usage_data_bytes=%v, got %v", tt.wantData, got)
+			}
+			if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_snapshots_bytes", labels); got != tt.wantSnapshots {
+				t.Errorf("expected storagebox_disk_usage_snapshots_bytes=%v, got %v", tt.wantSnapshots, got)
+			}
+		})
+	}
+}
+
+func TestCollectPaginationTruncated(t *testing.T) {
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		response := mockStorageBoxResponse()
+		response["meta"] = map[string]interface{}{
+			"pagination": map[string]interface{}{"page": 1, "next_page": 2},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+	client.SetMaxPages(1)
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{}))
+
+	if got := gaugeValue(t, reg, "storagebox_exporter_pagination_truncated"); got != 1 {
+		t.Errorf("expected pagination_truncated=1, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != 1 {
+		t.Errorf("expected truncated data to be served with up=1, got %v", got)
+	}
+}
+
+func TestCollectTypeChanges(t *testing.T) {
+	var boxType atomic.Value
+	boxType.Store("BX10")
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		response := mockStorageBoxResponse()
+		boxes := response["storage_boxes"].([]map[string]interface{})
+		boxes[0]["storage_box_type"].(map[string]interface{})["name"] = boxType.Load()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{}))
+	labels := map[string]string{"id": "12345"}
+
+	if got := labeledCounterValue(t, reg, "storagebox_type_changes_total", labels); got != 0 {
+		t.Errorf("expected no type change on the first scrape, got %v", got)
+	}
+	if got := labeledCounterValue(t, reg, "storagebox_type_changes_total", labels); got != 0 {
+		t.Errorf("expected no type change for an unchanged type, got %v", got)
+	}
+
+	boxType.Store("BX20")
+	if got := labeledCounterValue(t, reg, "storagebox_type_changes_total", labels); got != 1 {
+		t.Errorf("expected one type change after the upgrade, got %v", got)
+	}
+	if got := labeledCounterValue(t, reg, "storagebox_type_changes_total", map[string]string{"id": "12346"}); got != 0 {
+		t.Errorf("expected no type change for the untouched box, got %v", got)
+	}
+}
+
+func TestCollectNameConvention(t *testing.T) {
+	// The mock serves "test-storagebox" (12345) and "inactive-storagebox" (12346)
+	tests := []struct {
+		name       string
+		convention *regexp.Regexp
+		want12345  float64
+		want12346  float64
+	}{
+		{name: "disabled", convention: nil, want12345: -1, want12346: -1},
+		{name: "all conforming", convention: regexp.MustCompile(`^[a-z0-9-]+$`), want12345: 0, want12346: 0},
+		{name: "one violating", convention: regexp.MustCompile(`^test-`), want12345: 0, want12346: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+			collector.SetNameConvention(tt.convention)
+
+			if got := labeledGaugeValue(t, reg, "storagebox_name_convention_violation", map[string]string{"id": "12345"}); got != tt.want12345 {
+				t.Errorf("expected violation=%v for box 12345, got %v", tt.want12345, got)
+			}
+			if got := labeledGaugeValue(t, reg, "storagebox_name_convention_violation", map[string]string{"id": "12346"}); got != tt.want12346 {
+				t.Errorf("expected violation=%v for box 12346, got %v", tt.want12346, got)
+			}
+		})
+	}
+}
+
+func TestCollectRequiredLabels(t *testing.T) {
+	response := mockStorageBoxResponse()
+	boxes := response["storage_boxes"].([]map[string]interface{})
+	boxes[0]["labels"] = map[string]string{"owner": "ops", "team": ""}
+
+	reg, collector := newMockRegistry(t, response)
+	if got := labeledGaugeValue(t, reg, "storagebox_missing_required_label", map[string]string{"id": "12345"}); got != -1 {
+		t.Errorf("expected no metric without required labels, got %v", got)
+	}
+
+	collector.SetRequiredLabels([]string{"owner", "team", "cost-center"})
+	tests := []struct {
+		id    string
+		label string
+		want  float64
+	}{
+		{id: "12345", label: "owner", want: 0},
+		{id: "12345", label: "team", want: 0}, // an empty value still carries the key
+		{id: "12345", label: "cost-center", want: 1},
+		{id: "12346", label: "owner", want: 1},
+		{id: "12346", label: "team", want: 1},
+		{id: "12346", label: "cost-center", want: 1},
+	}
+	for _, tt := range tests {
+		labels := map[string]string{"id": tt.id, "label": tt.label}
+		if got := labeledGaugeValue(t, reg, "storagebox_missing_required_label", labels); got != tt.want {
+			t.Errorf("expected missing=%v for box %s label %s, got %v", tt.want, tt.id, tt.label, got)
+		}
+	}
+}
+
+func TestCollectExportedLabels(t *testing.T) {
+	response := mockStorageBoxResponse()
+	boxes := response["storage_boxes"].([]map[string]interface{})
+	boxes[0]["labels"] = map[string]string{"team": "storage", "cost-center": "42", "env": "prod"}
+
+	reg, collector := newMockRegistry(t, response)
+	if err := collector.SetExportedLabels([]string{"team", "cost-center"}); err != nil {
+		t.Fatalf("SetExportedLabels() unexpected error = %v", err)
+	}
+
+	labeled := map[string]string{"id": "12345", "label_team": "storage", "label_cost_center": "42"}
+	if got := labeledGaugeValue(t, reg, "storagebox_info", labeled); got != 1 {
+		t.Errorf("expected storagebox_info with the exported labels, got %v", got)
+	}
+	// A box without the labels gets empty values
+	unlabeled := map[string]string{"id": "12346", "label_team": "", "label_cost_center": ""}
+	if got := labeledGaugeValue(t, reg, "storagebox_info", unlabeled); got != 1 {
+		t.Errorf("expected storagebox_info with empty exported labels, got %v", got)
+	}
+	// Keys that are not exported stay out
+	if got := labeledGaugeValue(t, reg, "storagebox_info", map[string]string{"label_env": "prod"}); got != -1 {
+		t.Errorf("expected no label_env, got %v", got)
+	}
+
+	if err := collector.SetExportedLabels([]string{"cost-center", "cost_center"}); err == nil {
+		t.Error("SetExportedLabels() expected error for keys mapping to the same label name")
+	}
+}
+
+func TestCollectMaxLabelProjection(t *testing.T) {
+	response := mockStorageBoxResponse()
+	boxes := response["storage_boxes"].([]map[string]interface{})
+	boxes[0]["labels"] = map[string]string{"team": "storage", "env": "prod", "tier": "gold"}
+
+	reg, collector := newMockRegistry(t, response)
+	collector.SetMaxLabelProjection(2)
+	if err := collector.SetExportedLabels([]string{"team", "env", "tier"}); err != nil {
+		t.Fatalf("SetExportedLabels() unexpected error = %v", err)
+	}
+
+	// Keys up to the limit are kept in order, the rest is dropped
+	if got := labeledGaugeValue(t, reg, "storagebox_info", map[string]string{"id": "12345", "label_team": "storage", "label_env": "prod"}); got != 1 {
+		t.Errorf("expected storagebox_info with the first two exported labels, got %v", got)
+	}
+	if got := labeledGaugeValue(t, reg, "storagebox_info", map[string]string{"label_tier": "gold"}); got != -1 {
+		t.Errorf("expected label_tier beyond the limit to be dropped, got %v", got)
+	}
+}
+
+func TestCollectExternalAccessAlias(t *testing.T) {
+	// The mock serves 12345 reachable externally and 12346 not
+	for _, alias := range []bool{false, true} {
+		reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+		collector.SetExternalAccessAlias(alias)
+
+		for id, want := range map[string]float64{"12345": 1, "12346": 0} {
+			labels := map[string]string{"id": id}
+			if got := labeledGaugeValue(t, reg, "storagebox_reachable_externally", labels); got != want {
+				t.Errorf("alias=%v: expected storagebox_reachable_externally=%v for box %s, got %v", alias, want, id, got)
+			}
+			wantAlias := float64(-1)
+			if alias {
+				wantAlias = want
+			}
+			if got := labeledGaugeValue(t, reg, "storagebox_access_external_enabled", labels); got != wantAlias {
+				t.Errorf("alias=%v: expected storagebox_access_external_enabled=%v for box %s, got %v", alias, wantAlias, id, got)
+			}
+		}
+	}
+}
+
+func TestHandleErrorLogsChain(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	_, collector := newMockRegistry(t, mockStorageBoxResponse())
+	dnsErr := &net.DNSError{Err: "no such host", Name: "api.hetzner.com"}
+	err := fmt.Errorf("failed to execute request: %w", &url.Error{
+		Op:  "Get",
+		URL: "https://api.hetzner.com/v1/storage_boxes",
+		Err: &net.OpError{Op: "dial", Net: "tcp", Err: dnsErr},
+	})
+	collector.handleError(err, "direct_api_call")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("failed to decode log line %q: %v", logs.String(), err)
+	}
+	if entry["level"] != "ERROR" {
+		t.Errorf("expected the network error at error level, got %v", entry["level"])
+	}
+	if want := "*fmt.wrapError -> *url.Error -> *net.OpError -> *net.DNSError"; entry["error_chain"] != want {
+		t.Errorf("expected error_chain %q, got %v", want, entry["error_chain"])
+	}
+	if entry["root_cause"] != dnsErr.Error() {
+		t.Errorf("expected root_cause %q, got %v", dnsErr.Error(), entry["root_cause"])
+	}
+}
+
+func TestScrapeSummaryLog(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	var fail atomic.Bool
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+
+	collector := NewStorageBoxCollector(client, time.Minute, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	// Disabled by default
+	gaugeValue(t, reg, "storagebox_exporter_up")
+	if strings.Contains(logs.String(), "Scrape summary") {
+		t.Fatalf("expected no summary log by default, got %s", logs.String())
+	}
+
+	collector.SetScrapeSummaryLog(true)
+	gaugeValue(t, reg, "storagebox_exporter_up") // cache hit
+	collector.cache.Clear()
+	fail.Store(true)
+	gaugeValue(t, reg, "storagebox_exporter_up") // cache miss, API fails
+
+	var summaries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if entry["msg"] == "Scrape summary" {
+			summaries = append(summaries, entry)
+		}
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("expected one summary per scrape, got %d", len(summaries))
+	}
+
+	hit := summaries[0]
+	if hit["level"] != "INFO" || hit["boxes"] != float64(2) || hit["source"] != "cache_hit" || hit["errors"] != float64(0) {
+		t.Errorf("unexpected summary for a cached scrape: %v", hit)
+	}
+	if _, ok := hit["duration"]; !ok {
+		t.Errorf("expected a duration in the summary, got %v", hit)
+	}
+	if _, ok := hit["error"]; ok {
+		t.Errorf("expected no error in the summary of a successful scrape, got %v", hit)
+	}
+
+	failed := summaries[1]
+	if failed["boxes"] != float64(0) || failed["source"] != "cache_miss" || failed["errors"] != float64(1) || failed["error"] == nil {
+		t.Errorf("unexpected summary for a failed scrape: %v", failed)
+	}
+}
+
+func TestCollectAPIDeprecated(t *testing.T) {
+	var sunset atomic.Bool
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if sunset.Load() {
+			w.Header().Set("Sunset", "Wed, 11 Nov 2026 23:59:59 GMT")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{}))
+
+	if got := gaugeValue(t, reg, "storagebox_exporter_api_deprecated"); got != 0 {
+		t.Errorf("expected api_deprecated=0 without deprecation headers, got %v", got)
+	}
+	sunset.Store(true)
+	if got := gaugeValue(t, reg, "storagebox_exporter_api_deprecated"); got != 1 {
+		t.Errorf("expected api_deprecated=1 with a Sunset header, got %v", got)
+	}
+}
+
+func TestCollectBooleanStateset(t *testing.T) {
+	reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+	collector.SetBooleanStyle(true)
+	collector.SetAccessProtocolMetric(true)
+
+	// Box 12345: delete protection and SSH on, WebDAV off, snapshot plan enabled
+	tests := []struct {
+		metric string
+		labels map[string]string
+		want   float64
+	}{
+		{"storagebox_protection_delete", map[string]string{"id": "12345", "state": "enabled"}, 1},
+		{"storagebox_protection_delete", map[string]string{"id": "12345", "state": "disabled"}, 0},
+		{"storagebox_access_ssh_enabled", map[string]string{"id": "12345", "state": "enabled"}, 1},
+		{"storagebox_access_webdav_enabled", map[string]string{"id": "12345", "state": "disabled"}, 1},
+		{"storagebox_access", map[string]string{"id": "12345", "protocol": "webdav", "state": "enabled"}, 0},
+		{"storagebox_reachable_externally", map[string]string{"id": "12346", "state": "disabled"}, 1},
+		{"storagebox_snapshot_plan_enabled", map[string]string{"id": "12345", "state": "enabled"}, 1},
+		{"storagebox_snapshot_plan_enabled", map[string]string{"id": "12346", "state": "disabled"}, 1},
+	}
+	for _, tt := range tests {
+		if got := labeledGaugeValue(t, reg, tt.metric, tt.labels); got != tt.want {
+			t.Errorf("expected %s%v = %v, got %v", tt.metric, tt.labels, tt.want, got)
+		}
+	}
+
+	// Derived gauges keep the 1/0 style
+	if got := labeledGaugeValue(t, reg, "storagebox_access_external_mismatch", map[string]string{"id": "12345", "state": "enabled"}); got != -1 {
+		t.Errorf("expected no state label on storagebox_access_external_mismatch, got %v", got)
+	}
+}
+
+func TestCollectBooleanGaugeStyle(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	if got := labeledGaugeValue(t, reg, "storagebox_protection_delete", map[string]string{"id": "12345"}); got != 1 {
+		t.Errorf("expected storagebox_protection_delete=1 by default, got %v", got)
+	}
+	if got := labeledGaugeValue(t, reg, "storagebox_protection_delete", map[string]string{"id": "12345", "state": "enabled"}); got != -1 {
+		t.Errorf("expected no state label by default, got %v", got)
+	}
+}
+
+func TestCollectUpFailureGrace(t *testing.T) {
+	tests := []struct {
+		name       string
+		grace      time.Duration
+		failStatus int
+		wait       time.Duration
+		wantUp     float64
+	}{
+		{name: "transient within grace", grace: time.Minute, failStatus: http.StatusInternalServerError, wantUp: 1},
+		{name: "transient beyond grace", grace: 50 * time.Millisecond, failStatus: http.StatusInternalServerError, wait: 100 * time.Millisecond, wantUp: 0},
+		{name: "auth error within grace", grace: time.Minute, failStatus: http.StatusUnauthorized, wantUp: 0},
+		{name: "grace disabled", grace: 0, failStatus: http.StatusInternalServerError, wantUp: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var failing atomic.Bool
+			server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if failing.Load() {
+					w.WriteHeader(tt.failStatus)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+					t.Errorf("Failed to encode mock response: %v", err)
+				}
+			})
+			defer server.Close()
+
+			collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+			collector.SetUpFailureGrace(tt.grace)
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(collector)
+
+			if got := gaugeValue(t, reg, "storagebox_up"); got != 1 {
+				t.Fatalf("expected up=1 before the failures, got %v", got)
+			}
+
+			failing.Store(true)
+			if tt.wait > 0 {
+				// The first failure starts the streak
+				gaugeValue(t, reg, "storagebox_up")
+				time.Sleep(tt.wait)
+			}
+			if got := gaugeValue(t, reg, "storagebox_up"); got != tt.wantUp {
+				t.Errorf("expected up=%v, got %v", tt.wantUp, got)
+			}
+			wantUsage := float64(-1)
+			if tt.wantUp == 1 {
+				wantUsage = 536870912000
+			}
+			if got := labeledGaugeValue(t, reg, "storagebox_disk_usage_bytes", map[string]string{"id": "12345"}); got != wantUsage {
+				t.Errorf("expected storagebox_disk_usage_bytes=%v, got %v", wantUsage, got)
+			}
+		})
+	}
+}
+
+func TestCacheBackendInfo(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	if got := labeledGaugeValue(t, reg, "storagebox_exporter_cache_backend_info", map[string]string{"backend": "memory"}); got != 1 {
+		t.Errorf("expected cache_backend_info{backend=\"memory\"} = 1, got %v", got)
+	}
+	if got := labeledGaugeValue(t, reg, "storagebox_exporter_cache_backend_info", map[string]string{"backend": "redis"}); got != -1 {
+		t.Errorf("expected no redis backend series, got %v", got)
+	}
+}
+
+func TestAPIEndpointInfo(t *testing.T) {
+	server, client := setupMockServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mockStorageBoxResponse()); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	})
+	defer server.Close()
+	// Credentials in the base URL must not end up in the label
+	client.SetBaseURL(strings.Replace(server.URL, "http://", "http://user:secret@", 1))
+
+	collector := NewStorageBoxCollector(client, 0, 0, 0, BuildInfo{})
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	if got := labeledGaugeValue(t, reg, "storagebox_exporter_api_endpoint_info", map[string]string{"url": server.URL}); got != 1 {
+		t.Errorf("expected api_endpoint_info{url=%q} = 1, got %v", server.URL, got)
+	}
+}
+
+func TestSetRedisCache(t *testing.T) {
+	reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+	collector.SetCacheTTL(time.Minute)
+
+	if err := collector.SetRedisCache("http://localhost:6379"); err == nil {
+		t.Fatal("expected an error for a non-redis URL")
+	}
+
+	// Nothing listens on port 1: the cache misses and scrapes fall back to the API
+	if err := collector.SetRedisCache("redis://127.0.0.1:1"); err != nil {
+		t.Fatalf("SetRedisCache() unexpected error: %v", err)
+	}
+	if got := labeledGaugeValue(t, reg, "storagebox_exporter_cache_backend_info", map[string]string{"backend": "redis"}); got != 1 {
+		t.Errorf("expected cache_backend_info{backend=\"redis\"} = 1, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != 1 {
+		t.Errorf("expected up=1 with Redis unreachable, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_redis_up"); got != 0 {
+		t.Errorf("expected redis_up=0 with Redis unreachable, got %v", got)
+	}
+	if got := counterValue(t, reg, "storagebox_exporter_redis_errors_total"); got <= 0 {
+		t.Errorf("expected failed Redis commands to be counted, got %v", got)
+	}
+}
+
+func TestRedisHealthMetrics(t *testing.T) {
+	reg, collector := newMockRegistry(t, mockStorageBoxResponse())
+	if got := gaugeValue(t, reg, "storagebox_exporter_redis_up"); got != -1 {
+		t.Errorf("expected no redis_up with the memory cache, got %v", got)
+	}
+
+	server := miniredis.RunT(t)
+	collector.SetCacheTTL(time.Minute)
+	if err := collector.SetRedisCache("redis://" + server.Addr()); err != nil {
+		t.Fatalf("SetRedisCache() unexpected error: %v", err)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_redis_up"); got != 1 {
+		t.Errorf("expected redis_up=1 with Redis reachable, got %v", got)
+	}
+
+	// An outage turns the scrape's cache read into a miss, not a failure
+	server.SetError("LOADING Redis is loading the dataset in memory")
+	if got := gaugeValue(t, reg, "storagebox_exporter_up"); got != 1 {
+		t.Errorf("expected up=1 during the Redis outage, got %v", got)
+	}
+	if got := gaugeValue(t, reg, "storagebox_exporter_redis_up"); got != 0 {
+		t.Errorf("expected redis_up=0 during the Redis outage, got %v", got)
+	}
+	if got := counterValue(t, reg, "storagebox_exporter_redis_errors_total"); got < 1 {
+		t.Errorf("expected the failed Redis commands to be counted, got %v", got)
+	}
+}
+
+func TestDecodeCacheEntry(t *testing.T) {
+	oldest := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	roundTrip := func(entry cacheEntry) cacheEntry {
+		t.Helper()
+		raw, err := json.Marshal(entry)
+		if err != nil {
+			t.Fatalf("failed to encode cache entry: %v", err)
+		}
+		decoded, err := decodeCacheEntry(raw)
+		if err != nil {
+			t.Fatalf("decodeCacheEntry() unexpected error: %v", err)
+		}
+		return decoded.(cacheEntry)
+	}
+
+	got := roundTrip(cacheEntry{Boxes: []hetzner.StorageBox{{ID: 12345, Name: "test-storagebox", Stats: hetzner.Stats{Size: 1024}}}})
+	if len(got.Boxes) != 1 || got.Boxes[0].ID != 12345 || got.Boxes[0].Name != "test-storagebox" || got.Boxes[0].Stats.Size != 1024 {
+		t.Errorf("expected the storage boxes to round-trip, got %+v", got.Boxes)
+	}
+	if got.Snapshot != nil {
+		t.Errorf("expected no snapshot summary in a storage box entry, got %+v", got.Snapshot)
+	}
+
+	got = roundTrip(cacheEntry{Snapshot: &snapshotSummary{Count: 3, Oldest: oldest}})
+	if got.Snapshot == nil || got.Snapshot.Count != 3 || !got.Snapshot.Oldest.Equal(oldest) {
+		t.Errorf("expected the snapshot summary to round-trip, got %+v", got.Snapshot)
+	}
+}
+
+func TestCollectUsageRatioDistribution(t *testing.T) {
+	reg, _ := newMockRegistry(t, mockStorageBoxResponse())
+
+	histogram := func() *dto.Histogram {
+		t.Helper()
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+		for _, mf := range families {
+			if mf.GetName() == "storagebox_usage_ratio_distribution" {
+				return mf.GetMetric()[0].GetHistogram()
+			}
+		}
+		t.Fatal("storagebox_usage_ratio_distribution not found")
+		return nil
+	}
+
+	// Each scrape observes the current boxes only: 500GB of 1TB and 0 of 2TB
+	histogram()
+	h := histogram()
+	if got := h.GetSampleCount(); got != 2 {
+		t.Errorf("expected 2 observations on the second scrape, got %d", got)
+	}
+	wantSum := 536870912000.0 / 1099511627776.0
+	if got := h.GetSampleSum(); math.Abs(got-wantSum) > 1e-9 {
+		t.Errorf("expected sample sum %v, got %v", wantSum, got)
+	}
+
+	// Native histogram layout: schema 3, the empty boxes in the zero bucket
+	if got := h.GetSchema(); got != 3 {
+		t.Errorf("expected native histogram schema 3, got %d", got)
+	}
+	if got := h.GetZeroCount(); got != 1 {
+		t.Errorf("expected 1 observation in the zero bucket, got %d", got)
+	}
+	if len(h.GetPositiveSpan()) == 0 {
+		t.Error("expected populated positive native buckets")
+	}
+
+	// Classic buckets remain available
+	for _, b := range h.GetBucket() {
+		if b.GetUpperBound() == 0.5 && b.GetCumulativeCount() != 2 {
+			t.Errorf("expected both observations at or below 0.5, got %d", b.GetCumulativeCount())
+		}
+	}
+}
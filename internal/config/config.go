@@ -1,8 +1,13 @@
 package config
 
 import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
+	"log/slog"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -12,16 +17,78 @@ import (
 
 // Config holds the application configuration
 type Config struct {
-	HetznerToken         string
-	HetznerTokenFile     string
-	ListenAddress        string
-	MetricsPath          string
-	LogLevel             string
-	CacheTTL             time.Duration
-	CacheMaxSize         int64
-	CacheCleanupInterval time.Duration
-	CacheStorageType     string
-	ShowVersion          bool
+	HetznerToken          string
+	HetznerTokenFile      string
+	TokenSource           string // How the token was resolved: env, flag, file or config_file
+	ListenAddress         string
+	MetricsPath           string
+	LogLevel              string
+	ScrapeSummaryLog      bool
+	CacheTTL              time.Duration
+	CacheMaxSize          int64
+	CacheCleanupInterval  time.Duration
+	CacheStorageType      string
+	RedisURL              string
+	PaginationConcurrency int
+	APIRateLimit          float64
+	APISuccessWindow      int
+	APIMaxAttempts        int
+	APIRetryBaseDelay     time.Duration
+	MaxRetryAfter         time.Duration
+	AuthScheme            string
+	PaginationOnError     string
+	MaxPages              int
+	SuccessStatusCodes    []int
+	APITimeout            time.Duration
+	PollInterval          time.Duration
+	PollTimestamps        bool
+	MinScrapeInterval     time.Duration
+	UpFailureGrace        time.Duration
+	MaxConnsPerHost       int
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	ForceHTTP1            bool
+	ConnectionWarmup      bool
+	UsageCounter          bool
+	SizeUnit              string
+	BooleanStyle          string
+	ExternalAccessAlias   bool
+	AccessProtocolMetric  bool
+	SizeRound             bool
+	InfoCreatedLabel      bool
+	AllowRefresh          bool
+	MaxConcurrentScrapes  int
+	ScrapeQueueTimeout    time.Duration
+	SkipInactive          bool
+	SnapshotMetrics       bool
+	DetailConcurrency     int
+	NameConvention        *regexp.Regexp // nil when no naming convention is enforced
+	RequiredLabels        []string
+	ExportLabels          []string
+	MaxLabelProjection    int
+	ExcludeMetrics        []string
+	LabelRenames          map[string]string
+	OutputFile            string
+	OutputInterval        time.Duration
+	ConfigFile            string
+	LandingRedirect       bool
+	ExportCSV             bool
+	MetricsSince          bool
+	EnableAdminAPI        bool
+	AdminListenAddress    string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSMinVersion         string
+	TLSCipherSuites       []string
+	ShowVersion           bool
+	PrintMetricsSchema    bool
+	PrintScrapeConfig     bool
+
+	// Config file values read at startup or on the last reload, and the keys
+	// overridden by flags or environment variables
+	fileValues map[string]string
+	pinned     map[string]bool
 }
 
 // Load parses configuration from environment variables and command-line flags
@@ -33,6 +100,18 @@ func Load() (*Config, error) {
 	var cacheTTLFlag int
 	var cacheMaxSizeFlag int64
 	var cacheCleanupIntervalFlag int
+	var outputIntervalFlag int
+	var apiTimeoutFlag int
+	var apiRetryBaseDelayFlag int
+	var maxRetryAfterFlag int
+	var pollIntervalFlag int
+	var minScrapeIntervalFlag int
+	var upFailureGraceFlag int
+	var nameConventionFlag string
+	var successStatusCodesFlag []string
+	var labelRenameFlag []string
+	var scrapeQueueTimeoutFlag int
+	var dialTimeoutFlag, tlsHandshakeTimeoutFlag, responseHeaderTimeoutFlag int
 
 	// Define command-line flags
 	pflag.StringVar(&cfg.ListenAddress, "listen-address", getEnv("LISTEN_ADDRESS", ":9509"),
@@ -41,6 +120,8 @@ func Load() (*Config, error) {
 		"Path under which to expose metrics")
 	pflag.StringVar(&cfg.LogLevel, "log-level", getEnv("LOG_LEVEL", "info"),
 		"Log level (debug, info, warn, error)")
+	pflag.BoolVar(&cfg.ScrapeSummaryLog, "scrape-summary-log", getEnvBool("SCRAPE_SUMMARY_LOG", false),
+		"Log one info line per scrape with the boxes collected, their source (cache hit or miss, API, ...), duration and errors (can also be set via SCRAPE_SUMMARY_LOG env var)")
 	pflag.IntVar(&cacheTTLFlag, "cache-ttl", 0,
 		"Cache TTL in seconds, 0 to disable (can also be set via CACHE_TTL env var, default: 0 - disabled)")
 	pflag.Int64Var(&cacheMaxSizeFlag, "cache-max-size", 0,
@@ -49,18 +130,142 @@ func Load() (*Config, error) {
 		"Cache cleanup interval in seconds, 0 for default (can also be set via CACHE_CLEANUP_INTERVAL env var, default: 0 - 10s)")
 	pflag.StringVar(&cfg.CacheStorageType, "cache-storage-type", getEnv("CACHE_STORAGE_TYPE", "memory"),
 		"Cache storage type (memory, redis) (can also be set via CACHE_STORAGE_TYPE env var, default: memory)")
-	pflag.StringVar(&cfg.HetznerToken, "hetzner-token", os.Getenv("HETZNER_TOKEN"),
+	pflag.StringVar(&cfg.RedisURL, "redis-url", getEnv("REDIS_URL", ""),
+		"Redis URL redis://[[user]:password@]host[:port][/db], required with --cache-storage-type=redis (can also be set via REDIS_URL env var)")
+	pflag.StringVar(&cfg.HetznerToken, "hetzner-token", lookupEnv("HETZNER_TOKEN"),
 		"Hetzner API token (can also be set via HETZNER_TOKEN env var)")
-	pflag.StringVar(&cfg.HetznerTokenFile, "hetzner-token-file", os.Getenv("HETZNER_TOKEN_FILE"),
+	pflag.StringVar(&cfg.HetznerTokenFile, "hetzner-token-file", lookupEnv("HETZNER_TOKEN_FILE"),
 		"Path to file containing Hetzner API token (can also be set via HETZNER_TOKEN_FILE env var)")
+	pflag.IntVar(&cfg.PaginationConcurrency, "pagination-concurrency", getEnvInt("PAGINATION_CONCURRENCY", 1),
+		"Maximum number of API pages fetched in parallel, 1 for sequential (can also be set via PAGINATION_CONCURRENCY env var)")
+	pflag.StringVar(&cfg.AuthScheme, "auth-scheme", getEnv("AUTH_SCHEME", "Bearer"),
+		"Scheme of the Authorization header sent to the Hetzner API, e.g. for a gateway in between (Bearer, Token) (can also be set via AUTH_SCHEME env var)")
+	pflag.StringVar(&cfg.PaginationOnError, "pagination-on-error", getEnv("PAGINATION_ON_ERROR", "fail"),
+		"What to do when a page after the first fails: fail the scrape, or serve the boxes fetched so far (fail, partial) (can also be set via PAGINATION_ON_ERROR env var)")
+	pflag.IntVar(&cfg.MaxPages, "max-pages", getEnvInt("MAX_PAGES", 100),
+		"Maximum number of pages fetched per API call before pagination stops with a warning (can also be set via MAX_PAGES env var)")
+	pflag.StringSliceVar(&successStatusCodesFlag, "success-status-codes", getEnvList("SUCCESS_STATUS_CODES"),
+		"Comma-separated 2xx HTTP status codes whose API responses are decoded as success, empty for 200 only (can also be set via SUCCESS_STATUS_CODES env var)")
+	pflag.Float64Var(&cfg.APIRateLimit, "api-rate-limit", getEnvFloat("API_RATE_LIMIT", 0),
+		"Maximum Hetzner API requests per second, 0 for unlimited (can also be set via API_RATE_LIMIT env var)")
+	pflag.IntVar(&cfg.APISuccessWindow, "api-success-window", getEnvInt("API_SUCCESS_WINDOW", 10),
+		"Number of recent API calls used to compute the API success ratio (can also be set via API_SUCCESS_WINDOW env var)")
+	pflag.IntVar(&cfg.APIMaxAttempts, "api-max-attempts", getEnvInt("API_MAX_ATTEMPTS", 3),
+		"Maximum attempts per API request on rate limit or server errors, 1 disables retries (can also be set via API_MAX_ATTEMPTS env var)")
+	pflag.IntVar(&apiRetryBaseDelayFlag, "api-retry-base-delay", getEnvInt("API_RETRY_BASE_DELAY", 500),
+		"Wait in milliseconds before the first retry, doubled with jitter for each further retry (can also be set via API_RETRY_BASE_DELAY env var)")
+	pflag.IntVar(&maxRetryAfterFlag, "max-retry-after", getEnvInt("MAX_RETRY_AFTER", 60),
+		"Maximum seconds a retry waits when the API sends a longer Retry-After, 0 to ignore Retry-After (can also be set via MAX_RETRY_AFTER env var)")
+	pflag.IntVar(&apiTimeoutFlag, "api-timeout", getEnvInt("API_TIMEOUT", 30),
+		"Deadline in seconds for fetching all storage boxes, including pagination and retries (can also be set via API_TIMEOUT env var)")
+	pflag.IntVar(&pollIntervalFlag, "poll-interval", getEnvInt("POLL_INTERVAL", 0),
+		"Poll the Hetzner API in the background every this many seconds and serve scrapes from the last poll, 0 to call the API on scrape (can also be set via POLL_INTERVAL env var)")
+	pflag.BoolVar(&cfg.PollTimestamps, "poll-timestamps", getEnvBool("POLL_TIMESTAMPS", false),
+		"In poll mode, expose per-box metrics with the time of the poll that fetched them as timestamp (can also be set via POLL_TIMESTAMPS env var)")
+	pflag.IntVar(&upFailureGraceFlag, "up-failure-grace", getEnvInt("UP_FAILURE_GRACE", 0),
+		"Keep serving the last fetched data with up=1 while fetches have been failing for less than this many seconds; authentication errors are never graced, 0 to disable (can also be set via UP_FAILURE_GRACE env var)")
+	pflag.IntVar(&minScrapeIntervalFlag, "min-scrape-interval", getEnvInt("MIN_SCRAPE_INTERVAL", 0),
+		"Serve the previous result without an API call if the last fetch is younger than this many seconds, independent of the cache, 0 to disable (can also be set via MIN_SCRAPE_INTERVAL env var)")
+	pflag.IntVar(&cfg.MaxConnsPerHost, "max-conns-per-host", getEnvInt("MAX_CONNS_PER_HOST", 0),
+		"Maximum connections to the Hetzner API per host, 0 for unlimited (can also be set via MAX_CONNS_PER_HOST env var)")
+	pflag.IntVar(&dialTimeoutFlag, "dial-timeout", getEnvInt("DIAL_TIMEOUT", 0),
+		"Timeout in seconds for connecting to the Hetzner API, 0 for the Go default (can also be set via DIAL_TIMEOUT env var)")
+	pflag.IntVar(&tlsHandshakeTimeoutFlag, "tls-handshake-timeout", getEnvInt("TLS_HANDSHAKE_TIMEOUT", 0),
+		"Timeout in seconds for the TLS handshake with the Hetzner API, 0 for the Go default (can also be set via TLS_HANDSHAKE_TIMEOUT env var)")
+	pflag.IntVar(&responseHeaderTimeoutFlag, "response-header-timeout", getEnvInt("RESPONSE_HEADER_TIMEOUT", 0),
+		"Timeout in seconds for Hetzner API response headers after sending a request, 0 for no limit (can also be set via RESPONSE_HEADER_TIMEOUT env var)")
+	pflag.BoolVar(&cfg.ForceHTTP1, "force-http1", getEnvBool("FORCE_HTTP1", false),
+		"Pin Hetzner API connections to HTTP/1.1 for proxies that misbehave with HTTP/2 (can also be set via FORCE_HTTP1 env var)")
+	pflag.BoolVar(&cfg.ConnectionWarmup, "connection-warmup", getEnvBool("CONNECTION_WARMUP", false),
+		"Connect to the Hetzner API at startup so the first scrape reuses a pooled connection (can also be set via CONNECTION_WARMUP env var)")
+	pflag.BoolVar(&cfg.UsageCounter, "usage-counter", getEnvBool("USAGE_COUNTER", false),
+		"Expose storagebox_disk_usage_bytes_total, a synthetic counter of peak usage per box (can also be set via USAGE_COUNTER env var)")
+	pflag.StringVar(&cfg.BooleanStyle, "boolean-style", getEnv("BOOLEAN_STYLE", "gauge"),
+		"How boolean access, protection and snapshot metrics are exposed: a 1/0 gauge, or a stateset with a state label (gauge, stateset) (can also be set via BOOLEAN_STYLE env var)")
+	pflag.BoolVar(&cfg.ExternalAccessAlias, "access-external-alias", getEnvBool("ACCESS_EXTERNAL_ALIAS", false),
+		"Also expose storagebox_reachable_externally as storagebox_access_external_enabled (can also be set via ACCESS_EXTERNAL_ALIAS env var)")
+	pflag.BoolVar(&cfg.AccessProtocolMetric, "access-protocol-metric", getEnvBool("ACCESS_PROTOCOL_METRIC", false),
+		"Also expose the access settings as storagebox_access with a protocol label, doubling the access series per box (can also be set via ACCESS_PROTOCOL_METRIC env var)")
+	pflag.StringVar(&cfg.SizeUnit, "size-unit", getEnv("SIZE_UNIT", "bytes"),
+		"Unit of the emitted storagebox_disk_* size values (bytes, kib, mib, gib) (can also be set via SIZE_UNIT env var)")
+	pflag.BoolVar(&cfg.SizeRound, "size-round", getEnvBool("SIZE_ROUND", false),
+		"Round the emitted storagebox_disk_* size values to whole size units (can also be set via SIZE_ROUND env var)")
+	pflag.BoolVar(&cfg.InfoCreatedLabel, "info-created-label", getEnvBool("INFO_CREATED_LABEL", false),
+		"Add the creation time as an RFC 3339 created label to storagebox_info (can also be set via INFO_CREATED_LABEL env var)")
+	pflag.StringVar(&nameConventionFlag, "name-convention", getEnv("NAME_CONVENTION", ""),
+		"Regular expression storage box names must match, exposed as storagebox_name_convention_violation, empty to disable (can also be set via NAME_CONVENTION env var)")
+	pflag.BoolVar(&cfg.SkipInactive, "skip-inactive", getEnvBool("SKIP_INACTIVE", false),
+		"Omit per-box metrics for storage boxes whose status is not active (can also be set via SKIP_INACTIVE env var)")
+	pflag.BoolVar(&cfg.SnapshotMetrics, "snapshot-metrics", getEnvBool("SNAPSHOT_METRICS", false),
+		"Expose snapshot counts and the oldest snapshot per box, at the cost of one extra API call per box (can also be set via SNAPSHOT_METRICS env var)")
+	pflag.IntVar(&cfg.DetailConcurrency, "detail-concurrency", getEnvInt("DETAIL_CONCURRENCY", 4),
+		"Maximum number of per-box API calls, such as snapshot listings, made at once (can also be set via DETAIL_CONCURRENCY env var)")
+	pflag.StringSliceVar(&cfg.RequiredLabels, "require-label", getEnvList("REQUIRE_LABELS"),
+		"Label key every storage box must carry, repeatable or comma-separated (can also be set via REQUIRE_LABELS env var)")
+	pflag.StringSliceVar(&cfg.ExportLabels, "export-label", getEnvList("EXPORT_LABELS"),
+		"Label key of storage boxes to add to storagebox_info as label_<key>, repeatable or comma-separated (can also be set via EXPORT_LABELS env var)")
+	pflag.IntVar(&cfg.MaxLabelProjection, "max-label-projection", getEnvInt("MAX_LABEL_PROJECTION", 10),
+		"Maximum number of --export-label keys added to storagebox_info, extra keys are dropped with a warning; 0 for no limit (can also be set via MAX_LABEL_PROJECTION env var)")
+	pflag.StringSliceVar(&cfg.ExcludeMetrics, "exclude-metric", getEnvList("EXCLUDE_METRICS"),
+		"Metric name to suppress, repeatable or comma-separated (can also be set via EXCLUDE_METRICS env var)")
+	pflag.StringSliceVar(&labelRenameFlag, "label-rename", getEnvList("LABEL_RENAMES"),
+		"Rename a label on every metric as old=new, e.g. id=box_id, repeatable or comma-separated (can also be set via LABEL_RENAMES env var)")
+	pflag.StringVar(&cfg.OutputFile, "output-file", getEnv("OUTPUT_FILE", ""),
+		"Periodically write metrics to this file for the node_exporter textfile collector, in addition to serving HTTP (can also be set via OUTPUT_FILE env var)")
+	pflag.IntVar(&outputIntervalFlag, "output-interval", getEnvInt("OUTPUT_INTERVAL", 60),
+		"Interval in seconds between writes of --output-file (can also be set via OUTPUT_INTERVAL env var)")
+	pflag.BoolVar(&cfg.AllowRefresh, "allow-refresh", getEnvBool("ALLOW_REFRESH", false),
+		"Allow ?refresh=1 on the metrics path to bypass the cache for a single scrape (can also be set via ALLOW_REFRESH env var)")
+	pflag.IntVar(&cfg.MaxConcurrentScrapes, "max-concurrent-scrapes", getEnvInt("MAX_CONCURRENT_SCRAPES", 0),
+		"Maximum number of metrics scrapes served at once, 0 for unlimited; excess scrapes get 429 (can also be set via MAX_CONCURRENT_SCRAPES env var)")
+	pflag.IntVar(&scrapeQueueTimeoutFlag, "scrape-queue-timeout", getEnvInt("SCRAPE_QUEUE_TIMEOUT", 0),
+		"Seconds an excess scrape waits for a free slot before getting 503 instead of an immediate 429, 0 to not queue (can also be set via SCRAPE_QUEUE_TIMEOUT env var)")
+	pflag.BoolVar(&cfg.LandingRedirect, "landing-redirect", getEnvBool("LANDING_REDIRECT", false),
+		"Redirect / to the metrics path instead of serving the landing page (can also be set via LANDING_REDIRECT env var)")
+	pflag.BoolVar(&cfg.ExportCSV, "export-csv", getEnvBool("EXPORT_CSV", false),
+		"Serve the storage boxes as CSV on /export.csv for spreadsheet reports (can also be set via EXPORT_CSV env var)")
+	pflag.BoolVar(&cfg.MetricsSince, "metrics-since", getEnvBool("METRICS_SINCE", false),
+		"Serve per-box metrics of boxes created after ?since= on /metrics/since, from the data of the last scrape (can also be set via METRICS_SINCE env var)")
+	pflag.BoolVar(&cfg.EnableAdminAPI, "enable-admin-api", getEnvBool("ENABLE_ADMIN_API", false),
+		"Enable admin endpoints such as POST /pause and POST /resume (can also be set via ENABLE_ADMIN_API env var)")
+	pflag.StringVar(&cfg.AdminListenAddress, "admin-listen-address", getEnv("ADMIN_LISTEN_ADDRESS", ""),
+		"Address of a separate listener for admin endpoints, which implies --enable-admin-api; empty serves them on --listen-address (can also be set via ADMIN_LISTEN_ADDRESS env var)")
+	pflag.StringVar(&cfg.TLSCertFile, "tls-cert-file", getEnv("TLS_CERT_FILE", ""),
+		"Path to a TLS certificate; serves HTTPS when set together with --tls-key-file (can also be set via TLS_CERT_FILE env var)")
+	pflag.StringVar(&cfg.TLSKeyFile, "tls-key-file", getEnv("TLS_KEY_FILE", ""),
+		"Path to the TLS private key for --tls-cert-file (can also be set via TLS_KEY_FILE env var)")
+	pflag.StringVar(&cfg.TLSMinVersion, "tls-min-version", getEnv("TLS_MIN_VERSION", "1.2"),
+		"Minimum TLS version accepted when serving HTTPS (1.2, 1.3) (can also be set via TLS_MIN_VERSION env var)")
+	pflag.StringSliceVar(&cfg.TLSCipherSuites, "tls-cipher-suites", getEnvList("TLS_CIPHER_SUITES"),
+		"Comma-separated allowlist of TLS 1.2 cipher suites, empty for Go defaults (can also be set via TLS_CIPHER_SUITES env var)")
+	pflag.StringVar(&cfg.ConfigFile, "config-file", getEnv("CONFIG_FILE", ""),
+		"Path to a file of KEY=VALUE settings named like the env vars; LOG_LEVEL, CACHE_TTL and SKIP_INACTIVE are reloaded on SIGHUP (can also be set via CONFIG_FILE env var)")
 	pflag.BoolVar(&cfg.ShowVersion, "version", false,
 		"Show version information and exit")
+	pflag.BoolVar(&cfg.PrintMetricsSchema, "print-metrics-schema", false,
+		"Print the metric names and label keys as JSON and exit, without calling the API")
+	pflag.BoolVar(&cfg.PrintScrapeConfig, "print-scrape-config", false,
+		"Print a Prometheus scrape config for this exporter and exit")
 
 	pflag.Parse()
 
+	// Config file values fill in settings not given as flags or env vars
+	if cfg.ConfigFile != "" {
+		values, err := readConfigFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		pinned, err := applyConfigFile(pflag.CommandLine, values)
+		if err != nil {
+			return nil, err
+		}
+		cfg.fileValues = values
+		cfg.pinned = pinned
+	}
+
 	// Validate token configuration before reading from file
-	tokenFromEnv := os.Getenv("HETZNER_TOKEN")
-	tokenFileFromEnv := os.Getenv("HETZNER_TOKEN_FILE")
+	tokenFromEnv := lookupEnv("HETZNER_TOKEN")
+	tokenFileFromEnv := lookupEnv("HETZNER_TOKEN_FILE")
 
 	// Check if both token methods are specified in environment
 	if tokenFromEnv != "" && tokenFileFromEnv != "" {
@@ -82,6 +287,12 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("cannot specify both --hetzner-token-file and HETZNER_TOKEN environment variable")
 	}
 
+	if looksLikeTokenPath(cfg.HetznerToken) {
+		slog.Warn("Hetzner token looks like a file path, it will be sent as the bearer token as-is; use HETZNER_TOKEN_FILE or --hetzner-token-file to read the token from a file",
+			"path", cfg.HetznerToken,
+		)
+	}
+
 	// Read token from file if specified
 	if cfg.HetznerTokenFile != "" {
 		token, err := readTokenFromFile(cfg.HetznerTokenFile)
@@ -90,11 +301,12 @@ func Load() (*Config, error) {
 		}
 		cfg.HetznerToken = token
 	}
+	cfg.TokenSource = cfg.tokenSource()
 
 	// Determine cache TTL: flag > env var > default (0 = disabled)
 	if cacheTTLFlag > 0 {
 		cacheTTLSeconds = cacheTTLFlag
-	} else if envTTL := os.Getenv("CACHE_TTL"); envTTL != "" {
+	} else if envTTL := lookupEnv("CACHE_TTL"); envTTL != "" {
 		if parsed, err := strconv.Atoi(envTTL); err == nil && parsed >= 0 {
 			cacheTTLSeconds = parsed
 		}
@@ -104,7 +316,7 @@ func Load() (*Config, error) {
 	// Determine cache max size: flag > env var > default (0 = unlimited)
 	if cacheMaxSizeFlag > 0 {
 		cfg.CacheMaxSize = cacheMaxSizeFlag
-	} else if envSize := os.Getenv("CACHE_MAX_SIZE"); envSize != "" {
+	} else if envSize := lookupEnv("CACHE_MAX_SIZE"); envSize != "" {
 		if parsed, err := strconv.ParseInt(envSize, 10, 64); err == nil && parsed >= 0 {
 			cfg.CacheMaxSize = parsed
 		}
@@ -116,15 +328,182 @@ func Load() (*Config, error) {
 	cleanupSeconds := 10 // default
 	if cacheCleanupIntervalFlag > 0 {
 		cleanupSeconds = cacheCleanupIntervalFlag
-	} else if envCleanup := os.Getenv("CACHE_CLEANUP_INTERVAL"); envCleanup != "" {
+	} else if envCleanup := lookupEnv("CACHE_CLEANUP_INTERVAL"); envCleanup != "" {
 		if parsed, err := strconv.Atoi(envCleanup); err == nil && parsed > 0 {
 			cleanupSeconds = parsed
 		}
 	}
 	cfg.CacheCleanupInterval = time.Duration(cleanupSeconds) * time.Second
 
+	switch cfg.CacheStorageType {
+	case "memory":
+	case "redis":
+		if cfg.RedisURL == "" {
+			return nil, fmt.Errorf("cache storage type redis requires --redis-url or REDIS_URL")
+		}
+	default:
+		return nil, fmt.Errorf("invalid cache storage type %q, must be memory or redis", cfg.CacheStorageType)
+	}
+
+	if outputIntervalFlag < 1 {
+		return nil, fmt.Errorf("output interval must be at least 1 second, got %d", outputIntervalFlag)
+	}
+	cfg.OutputInterval = time.Duration(outputIntervalFlag) * time.Second
+
+	if cfg.PaginationConcurrency < 1 {
+		return nil, fmt.Errorf("pagination concurrency must be at least 1, got %d", cfg.PaginationConcurrency)
+	}
+
+	if cfg.AuthScheme != "Bearer" && cfg.AuthScheme != "Token" {
+		return nil, fmt.Errorf("invalid auth-scheme %q, must be one of: Bearer, Token", cfg.AuthScheme)
+	}
+
+	if cfg.PaginationOnError != "fail" && cfg.PaginationOnError != "partial" {
+		return nil, fmt.Errorf("invalid pagination-on-error %q, must be one of: fail, partial", cfg.PaginationOnError)
+	}
+
+	if cfg.BooleanStyle != "gauge" && cfg.BooleanStyle != "stateset" {
+		return nil, fmt.Errorf("invalid boolean-style %q, must be one of: gauge, stateset", cfg.BooleanStyle)
+	}
+
+	switch cfg.SizeUnit {
+	case "bytes", "kib", "mib", "gib":
+	default:
+		return nil, fmt.Errorf("invalid size-unit %q, must be one of: bytes, kib, mib, gib", cfg.SizeUnit)
+	}
+
+	if nameConventionFlag != "" {
+		re, err := regexp.Compile(nameConventionFlag)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name-convention %q: %w", nameConventionFlag, err)
+		}
+		cfg.NameConvention = re
+	}
+
+	// Canonicalize /metrics/ to /metrics, the handler serves both
+	if !strings.HasPrefix(cfg.MetricsPath, "/") {
+		return nil, fmt.Errorf("metrics path must start with /, got %q", cfg.MetricsPath)
+	}
+	if trimmed := strings.TrimRight(cfg.MetricsPath, "/"); trimmed != "" {
+		cfg.MetricsPath = trimmed
+	}
+
+	if cfg.MaxPages < 1 {
+		return nil, fmt.Errorf("max pages must be at least 1, got %d", cfg.MaxPages)
+	}
+	if cfg.DetailConcurrency < 1 {
+		return nil, fmt.Errorf("detail concurrency must be at least 1, got %d", cfg.DetailConcurrency)
+	}
+	if cfg.MaxLabelProjection < 0 {
+		return nil, fmt.Errorf("max label projection must not be negative, got %d", cfg.MaxLabelProjection)
+	}
+
+	for _, value := range labelRenameFlag {
+		from, to, ok := strings.Cut(value, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid label rename %q, expected old=new", value)
+		}
+		if _, dup := cfg.LabelRenames[from]; dup {
+			return nil, fmt.Errorf("label %q renamed more than once", from)
+		}
+		if cfg.LabelRenames == nil {
+			cfg.LabelRenames = make(map[string]string)
+		}
+		cfg.LabelRenames[from] = to
+	}
+
+	for _, value := range successStatusCodesFlag {
+		code, err := strconv.Atoi(value)
+		if err != nil || code < 200 || code > 299 {
+			return nil, fmt.Errorf("invalid success status code %q, must be a 2xx HTTP status code", value)
+		}
+		cfg.SuccessStatusCodes = append(cfg.SuccessStatusCodes, code)
+	}
+
+	if cfg.APIRateLimit < 0 {
+		return nil, fmt.Errorf("API rate limit must not be negative, got %v", cfg.APIRateLimit)
+	}
+
+	if cfg.APISuccessWindow < 1 {
+		return nil, fmt.Errorf("API success window must be at least 1, got %d", cfg.APISuccessWindow)
+	}
+
+	if apiTimeoutFlag < 1 {
+		return nil, fmt.Errorf("API timeout must be at least 1 second, got %d", apiTimeoutFlag)
+	}
+	cfg.APITimeout = time.Duration(apiTimeoutFlag) * time.Second
+
+	if pollIntervalFlag < 0 {
+		return nil, fmt.Errorf("poll interval must not be negative, got %d", pollIntervalFlag)
+	}
+	cfg.PollInterval = time.Duration(pollIntervalFlag) * time.Second
+
+	if minScrapeIntervalFlag < 0 {
+		return nil, fmt.Errorf("minimum scrape interval must not be negative, got %d", minScrapeIntervalFlag)
+	}
+	cfg.MinScrapeInterval = time.Duration(minScrapeIntervalFlag) * time.Second
+
+	if upFailureGraceFlag < 0 {
+		return nil, fmt.Errorf("up failure grace must not be negative, got %d", upFailureGraceFlag)
+	}
+	cfg.UpFailureGrace = time.Duration(upFailureGraceFlag) * time.Second
+
+	if cfg.MaxConcurrentScrapes < 0 {
+		return nil, fmt.Errorf("max concurrent scrapes must not be negative, got %d", cfg.MaxConcurrentScrapes)
+	}
+
+	if scrapeQueueTimeoutFlag < 0 {
+		return nil, fmt.Errorf("scrape queue timeout must not be negative, got %d", scrapeQueueTimeoutFlag)
+	}
+	cfg.ScrapeQueueTimeout = time.Duration(scrapeQueueTimeoutFlag) * time.Second
+
+	if cfg.MaxConnsPerHost < 0 {
+		return nil, fmt.Errorf("max connections per host must not be negative, got %d", cfg.MaxConnsPerHost)
+	}
+
+	for _, timeout := range []struct {
+		name    string
+		seconds int
+	}{
+		{"dial timeout", dialTimeoutFlag},
+		{"TLS handshake timeout", tlsHandshakeTimeoutFlag},
+		{"response header timeout", responseHeaderTimeoutFlag},
+	} {
+		if timeout.seconds < 0 {
+			return nil, fmt.Errorf("%s must not be negative, got %d", timeout.name, timeout.seconds)
+		}
+	}
+	cfg.DialTimeout = time.Duration(dialTimeoutFlag) * time.Second
+	cfg.TLSHandshakeTimeout = time.Duration(tlsHandshakeTimeoutFlag) * time.Second
+	cfg.ResponseHeaderTimeout = time.Duration(responseHeaderTimeoutFlag) * time.Second
+
+	if cfg.APIMaxAttempts < 1 {
+		return nil, fmt.Errorf("API max attempts must be at least 1, got %d", cfg.APIMaxAttempts)
+	}
+	if apiRetryBaseDelayFlag < 0 {
+		return nil, fmt.Errorf("API retry base delay must not be negative, got %d", apiRetryBaseDelayFlag)
+	}
+	cfg.APIRetryBaseDelay = time.Duration(apiRetryBaseDelayFlag) * time.Millisecond
+	if maxRetryAfterFlag < 0 {
+		return nil, fmt.Errorf("max retry after must not be negative, got %d", maxRetryAfterFlag)
+	}
+	cfg.MaxRetryAfter = time.Duration(maxRetryAfterFlag) * time.Second
+
+	if cfg.AdminListenAddress != "" && cfg.AdminListenAddress == cfg.ListenAddress {
+		return nil, fmt.Errorf("admin listen address must differ from listen address %s", cfg.ListenAddress)
+	}
+
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return nil, fmt.Errorf("--tls-cert-file and --tls-key-file must be set together")
+	}
+
+	if _, err := cfg.TLSConfig(); err != nil {
+		return nil, err
+	}
+
 	// Validate that at least one token method is provided
-	if !cfg.ShowVersion && cfg.HetznerToken == "" && cfg.HetznerTokenFile == "" &&
+	if !cfg.ShowVersion && !cfg.PrintMetricsSchema && !cfg.PrintScrapeConfig && cfg.HetznerToken == "" && cfg.HetznerTokenFile == "" &&
 		tokenFromEnv == "" && tokenFileFromEnv == "" {
 		return nil, fmt.Errorf("HETZNER_TOKEN or HETZNER_TOKEN_FILE environment variable is required (or corresponding flags)")
 	}
@@ -132,14 +511,158 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// tokenSource reports how the token was resolved. Config file values are
+// applied as flags, so they are told apart by not being pinned.
+func (c *Config) tokenSource() string {
+	switch {
+	case c.HetznerTokenFile != "":
+		return "file"
+	case c.fileValues["HETZNER_TOKEN"] != "" && !c.pinned["HETZNER_TOKEN"]:
+		return "config_file"
+	case pflag.CommandLine.Changed("hetzner-token"):
+		return "flag"
+	case lookupEnv("HETZNER_TOKEN") != "":
+		return "env"
+	default:
+		return ""
+	}
+}
+
+// TLSEnabled reports whether the exporter serves HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// tlsVersions maps accepted --tls-min-version values to crypto/tls constants
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig builds the server tls.Config from the TLS minimum version and
+// cipher suite settings. Only cipher suites Go considers secure are accepted.
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	minVersion, ok := tlsVersions[c.TLSMinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS minimum version %q, must be one of: 1.2, 1.3", c.TLSMinVersion)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: minVersion}
+	if len(c.TLSCipherSuites) == 0 {
+		return tlsConfig, nil
+	}
+
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	for _, name := range c.TLSCipherSuites {
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+	}
+	return tlsConfig, nil
+}
+
+// envPrefixVar names the environment variable holding an optional prefix that
+// is prepended to every other environment variable the exporter reads
+const envPrefixVar = "ENV_PREFIX"
+
+// lookupEnv retrieves an environment variable, prepending ENV_PREFIX to its
+// name when set (e.g. ENV_PREFIX=SBX turns HETZNER_TOKEN into SBX_HETZNER_TOKEN)
+func lookupEnv(key string) string {
+	prefix := os.Getenv(envPrefixVar)
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return os.Getenv(prefix + key)
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value := lookupEnv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
+// getEnvInt retrieves an integer environment variable or returns a default value
+// when the variable is unset or not a valid integer
+func getEnvInt(key string, defaultValue int) int {
+	if value := lookupEnv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvBool retrieves a boolean environment variable or returns a default value
+// when the variable is unset or not a valid boolean
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := lookupEnv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvFloat retrieves a floating point environment variable or returns a
+// default value when the variable is unset or not a valid number
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := lookupEnv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList retrieves a comma-separated environment variable as a list,
+// trimming whitespace and dropping empty entries
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(lookupEnv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// looksLikeTokenPath reports whether token is likely a token file path set by
+// mistake instead of the token itself: it starts like a path and the file exists
+func looksLikeTokenPath(token string) bool {
+	if !strings.HasPrefix(token, "/") && !strings.HasPrefix(token, "./") {
+		return false
+	}
+	info, err := os.Stat(token)
+	return err == nil && !info.IsDir()
+}
+
+// ReloadToken re-reads the token from the configured token file. ok is false
+// when the token is not file-based and there is nothing to reload.
+func (c *Config) ReloadToken() (token string, ok bool, err error) {
+	if c.HetznerTokenFile == "" {
+		return "", false, nil
+	}
+	token, err = readTokenFromFile(c.HetznerTokenFile)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to read token from file %s: %w", c.HetznerTokenFile, err)
+	}
+	return token, true, nil
+}
+
+// TokenFingerprint returns a short, non-reversible identifier of a token, safe
+// to log to tell tokens apart without revealing them
+func TokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:4])
+}
+
 // readTokenFromFile reads the Hetzner API token from a file
 func readTokenFromFile(filename string) (string, error) {
 	data, err := os.ReadFile(filename)
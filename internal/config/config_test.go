@@ -1,8 +1,12 @@
 package config
 
 import (
+	"bytes"
+	"crypto/tls"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -120,6 +124,60 @@ func TestLoad(t *testing.T) {
 			wantErr:     true,
 			errContains: "failed to read token from file",
 		},
+		{
+			name: "invalid size unit should fail",
+			envVars: map[string]string{
+				"HETZNER_TOKEN": "test-token-env",
+			},
+			args:        []string{"--size-unit=tib"},
+			wantErr:     true,
+			errContains: "invalid size-unit",
+		},
+		{
+			name: "invalid name convention should fail",
+			envVars: map[string]string{
+				"HETZNER_TOKEN": "test-token-env",
+			},
+			args:        []string{"--name-convention=[a-z"},
+			wantErr:     true,
+			errContains: "invalid name-convention",
+		},
+		{
+			name: "redis cache without URL should fail",
+			envVars: map[string]string{
+				"HETZNER_TOKEN": "test-token-env",
+			},
+			args:        []string{"--cache-storage-type=redis"},
+			wantErr:     true,
+			errContains: "requires --redis-url",
+		},
+		{
+			name: "unknown cache storage type should fail",
+			envVars: map[string]string{
+				"HETZNER_TOKEN": "test-token-env",
+			},
+			args:        []string{"--cache-storage-type=memcached"},
+			wantErr:     true,
+			errContains: "invalid cache storage type",
+		},
+		{
+			name: "negative retry base delay should fail",
+			envVars: map[string]string{
+				"HETZNER_TOKEN": "test-token-env",
+			},
+			args:        []string{"--api-retry-base-delay=-1"},
+			wantErr:     true,
+			errContains: "retry base delay must not be negative",
+		},
+		{
+			name: "negative max retry after should fail",
+			envVars: map[string]string{
+				"HETZNER_TOKEN": "test-token-env",
+			},
+			args:        []string{"--max-retry-after=-1"},
+			wantErr:     true,
+			errContains: "max retry after must not be negative",
+		},
 		{
 			name: "non-existent token file should fail",
 			envVars: map[string]string{
@@ -347,6 +405,7 @@ func TestLoadCacheConfiguration(t *testing.T) {
 				"CACHE_MAX_SIZE":         "1048576",
 				"CACHE_CLEANUP_INTERVAL": "30",
 				"CACHE_STORAGE_TYPE":     "redis",
+				"REDIS_URL":              "redis://localhost:6379/0",
 			},
 			expectedTTL:         60 * time.Second,
 			expectedMaxSize:     1048576,
@@ -425,3 +484,455 @@ func TestLoadCacheConfiguration(t *testing.T) {
 		})
 	}
 }
+
+// resetFlags prepares pflag and os.Args for a fresh call to Load.
+func resetFlags(args ...string) {
+	pflag.CommandLine = pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+	os.Args = append([]string{"test"}, args...)
+}
+
+func TestLoadEnvPrefix(t *testing.T) {
+	tests := []struct {
+		name          string
+		envVars       map[string]string
+		wantErr       bool
+		expectedToken string
+		expectedTTL   time.Duration
+	}{
+		{
+			name: "no prefix reads unprefixed variables",
+			envVars: map[string]string{
+				"HETZNER_TOKEN": "plain-token",
+				"CACHE_TTL":     "30",
+			},
+			expectedToken: "plain-token",
+			expectedTTL:   30 * time.Second,
+		},
+		{
+			name: "prefix reads prefixed variables",
+			envVars: map[string]string{
+				"ENV_PREFIX":        "SBX",
+				"SBX_HETZNER_TOKEN": "prefixed-token",
+				"SBX_CACHE_TTL":     "45",
+				"HETZNER_TOKEN":     "other-exporter-token",
+				"CACHE_TTL":         "10",
+			},
+			expectedToken: "prefixed-token",
+			expectedTTL:   45 * time.Second,
+		},
+		{
+			name: "prefix with trailing underscore",
+			envVars: map[string]string{
+				"ENV_PREFIX":        "SBX_",
+				"SBX_HETZNER_TOKEN": "prefixed-token",
+			},
+			expectedToken: "prefixed-token",
+		},
+		{
+			name: "prefix ignores unprefixed token",
+			envVars: map[string]string{
+				"ENV_PREFIX":    "SBX",
+				"HETZNER_TOKEN": "other-exporter-token",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.envVars {
+				t.Setenv(k, v)
+			}
+			resetFlags()
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Load() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+			if cfg.HetznerToken != tt.expectedToken {
+				t.Errorf("Load() HetznerToken = %v, want %v", cfg.HetznerToken, tt.expectedToken)
+			}
+			if cfg.CacheTTL != tt.expectedTTL {
+				t.Errorf("Load() CacheTTL = %v, want %v", cfg.CacheTTL, tt.expectedTTL)
+			}
+		})
+	}
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		wantErr         bool
+		errContains     string
+		expectedVersion uint16
+		expectedSuites  []uint16
+	}{
+		{
+			name:            "defaults to TLS 1.2 and Go cipher suites",
+			expectedVersion: tls.VersionTLS12,
+		},
+		{
+			name:            "TLS 1.3",
+			args:            []string{"--tls-min-version=1.3"},
+			expectedVersion: tls.VersionTLS13,
+		},
+		{
+			name:            "cipher allowlist",
+			args:            []string{"--tls-cipher-suites=TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"},
+			expectedVersion: tls.VersionTLS12,
+			expectedSuites: []uint16{
+				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+				tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			},
+		},
+		{
+			name:        "unknown version",
+			args:        []string{"--tls-min-version=1.0"},
+			wantErr:     true,
+			errContains: "unsupported TLS minimum version",
+		},
+		{
+			name:        "unknown cipher suite",
+			args:        []string{"--tls-cipher-suites=TLS_NOT_A_SUITE"},
+			wantErr:     true,
+			errContains: "unknown or insecure TLS cipher suite",
+		},
+		{
+			name:        "insecure cipher suite",
+			args:        []string{"--tls-cipher-suites=TLS_RSA_WITH_RC4_128_SHA"},
+			wantErr:     true,
+			errContains: "unknown or insecure TLS cipher suite",
+		},
+		{
+			name:        "certificate without key",
+			args:        []string{"--tls-cert-file=cert.pem"},
+			wantErr:     true,
+			errContains: "must be set together",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HETZNER_TOKEN", "test-token")
+			resetFlags(tt.args...)
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Load() expected error but got none")
+				}
+				if !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("Load() error = %v, want error containing %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+
+			tlsConfig, err := cfg.TLSConfig()
+			if err != nil {
+				t.Fatalf("TLSConfig() unexpected error = %v", err)
+			}
+			if tlsConfig.MinVersion != tt.expectedVersion {
+				t.Errorf("TLSConfig() MinVersion = %x, want %x", tlsConfig.MinVersion, tt.expectedVersion)
+			}
+			if len(tlsConfig.CipherSuites) != len(tt.expectedSuites) {
+				t.Fatalf("TLSConfig() CipherSuites = %v, want %v", tlsConfig.CipherSuites, tt.expectedSuites)
+			}
+			for i := range tt.expectedSuites {
+				if tlsConfig.CipherSuites[i] != tt.expectedSuites[i] {
+					t.Errorf("TLSConfig() CipherSuites = %v, want %v", tlsConfig.CipherSuites, tt.expectedSuites)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadWarnsOnPathLikeToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantWarn bool
+	}{
+		{name: "existing absolute path", token: tokenFile, wantWarn: true},
+		{name: "missing path", token: "/nonexistent/token", wantWarn: false},
+		{name: "regular token", token: "abc123", wantWarn: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			previous := slog.Default()
+			slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+			defer slog.SetDefault(previous)
+
+			t.Setenv("HETZNER_TOKEN", tt.token)
+			resetFlags()
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+			if cfg.HetznerToken != tt.token {
+				t.Errorf("Load() HetznerToken = %v, want %v", cfg.HetznerToken, tt.token)
+			}
+
+			warned := strings.Contains(buf.String(), "HETZNER_TOKEN_FILE")
+			if warned != tt.wantWarn {
+				t.Errorf("expected warning %v, got log output %q", tt.wantWarn, buf.String())
+			}
+		})
+	}
+}
+
+func TestLoadSuccessStatusCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    []int
+		wantErr bool
+	}{
+		{name: "default", args: nil, want: nil},
+		{name: "list", args: []string{"--success-status-codes=200,203"}, want: []int{200, 203}},
+		{name: "not a number", args: []string{"--success-status-codes=ok"}, wantErr: true},
+		{name: "not 2xx", args: []string{"--success-status-codes=200,404"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HETZNER_TOKEN", "test-token")
+			resetFlags(tt.args...)
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Load() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+			if !slices.Equal(cfg.SuccessStatusCodes, tt.want) {
+				t.Errorf("Load() SuccessStatusCodes = %v, want %v", cfg.SuccessStatusCodes, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMetricsPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", env: "", want: "/metrics"},
+		{name: "trailing slash", env: "/metrics/", want: "/metrics"},
+		{name: "nested with trailing slashes", env: "/exporter/metrics//", want: "/exporter/metrics"},
+		{name: "root", env: "/", want: "/"},
+		{name: "relative", env: "metrics", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HETZNER_TOKEN", "test-token")
+			if tt.env != "" {
+				t.Setenv("METRICS_PATH", tt.env)
+			}
+			resetFlags()
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Load() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+			if cfg.MetricsPath != tt.want {
+				t.Errorf("Load() MetricsPath = %q, want %q", cfg.MetricsPath, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadTokenSource(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("file-token"), 0600); err != nil {
+		t.Fatalf("Failed to create test token file: %v", err)
+	}
+	configFile := filepath.Join(dir, "exporter.conf")
+	if err := os.WriteFile(configFile, []byte("HETZNER_TOKEN=config-token\n"), 0600); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		envVars map[string]string
+		args    []string
+		want    string
+	}{
+		{name: "env", envVars: map[string]string{"HETZNER_TOKEN": "env-token"}, want: "env"},
+		{name: "flag", args: []string{"--hetzner-token=flag-token"}, want: "flag"},
+		{name: "file via env", envVars: map[string]string{"HETZNER_TOKEN_FILE": tokenFile}, want: "file"},
+		{name: "file via flag", args: []string{"--hetzner-token-file=" + tokenFile}, want: "file"},
+		{name: "config file", args: []string{"--config-file=" + configFile}, want: "config_file"},
+		{name: "env overrides config file", envVars: map[string]string{"HETZNER_TOKEN": "env-token"}, args: []string{"--config-file=" + configFile}, want: "env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.envVars {
+				t.Setenv(key, value)
+			}
+			resetFlags(tt.args...)
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+			if cfg.TokenSource != tt.want {
+				t.Errorf("Load() TokenSource = %v, want %v", cfg.TokenSource, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadLabelRenames(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "default", want: nil},
+		{name: "comma-separated", args: []string{"--label-rename=id=box_id, location=dc"}, want: map[string]string{"id": "box_id", "location": "dc"}},
+		{name: "repeated", args: []string{"--label-rename=id=box_id", "--label-rename=name=box_name"}, want: map[string]string{"id": "box_id", "name": "box_name"}},
+		{name: "missing target", args: []string{"--label-rename=id"}, wantErr: true},
+		{name: "empty target", args: []string{"--label-rename=id="}, wantErr: true},
+		{name: "renamed twice", args: []string{"--label-rename=id=a,id=b"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HETZNER_TOKEN", "test-token")
+			resetFlags(tt.args...)
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Load() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+			if len(cfg.LabelRenames) != len(tt.want) {
+				t.Fatalf("Load() LabelRenames = %v, want %v", cfg.LabelRenames, tt.want)
+			}
+			for from, to := range tt.want {
+				if cfg.LabelRenames[from] != to {
+					t.Errorf("Load() LabelRenames[%q] = %q, want %q", from, cfg.LabelRenames[from], to)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadDetailConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{name: "default", want: 4},
+		{name: "flag", args: []string{"--detail-concurrency=8"}, want: 8},
+		{name: "env", env: "2", want: 2},
+		{name: "serial", args: []string{"--detail-concurrency=1"}, want: 1},
+		{name: "zero", args: []string{"--detail-concurrency=0"}, wantErr: true},
+		{name: "negative", env: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HETZNER_TOKEN", "test-token")
+			if tt.env != "" {
+				t.Setenv("DETAIL_CONCURRENCY", tt.env)
+			}
+			resetFlags(tt.args...)
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Load() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+			if cfg.DetailConcurrency != tt.want {
+				t.Errorf("Load() DetailConcurrency = %d, want %d", cfg.DetailConcurrency, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAPIMaxAttempts(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{name: "default retries twice", want: 3},
+		{name: "flag", args: []string{"--api-max-attempts=5"}, want: 5},
+		{name: "env disables retries", env: "1", want: 1},
+		{name: "zero", args: []string{"--api-max-attempts=0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HETZNER_TOKEN", "test-token")
+			if tt.env != "" {
+				t.Setenv("API_MAX_ATTEMPTS", tt.env)
+			}
+			resetFlags(tt.args...)
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Load() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+			if cfg.APIMaxAttempts != tt.want {
+				t.Errorf("Load() APIMaxAttempts = %d, want %d", cfg.APIMaxAttempts, tt.want)
+			}
+		})
+	}
+}
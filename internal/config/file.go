@@ -0,0 +1,187 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// reloadableKeys are the config file settings that take effect on SIGHUP
+// without a restart. All other settings are only read at startup.
+var reloadableKeys = map[string]bool{
+	"LOG_LEVEL":     true,
+	"CACHE_TTL":     true,
+	"SKIP_INACTIVE": true,
+}
+
+// readConfigFile parses a config file of KEY=VALUE lines, where keys are the
+// environment variable names without ENV_PREFIX. Blank lines and lines
+// starting with # are ignored.
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("config file %s line %d: expected KEY=VALUE", path, lineNo)
+		}
+		key = strings.TrimSpace(key)
+		values[key] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return values, nil
+}
+
+// flagEnvNames maps the command-line flags whose environment variable is not
+// just the upper-cased flag name to that variable
+var flagEnvNames = map[string]string{
+	"require-label":  "REQUIRE_LABELS",
+	"export-label":   "EXPORT_LABELS",
+	"exclude-metric": "EXCLUDE_METRICS",
+	"label-rename":   "LABEL_RENAMES",
+}
+
+// configKey returns the config key for a command-line flag, which is the name
+// of its environment variable, e.g. --cache-ttl maps to CACHE_TTL and
+// --export-label to EXPORT_LABELS
+func configKey(name string) string {
+	if key, ok := flagEnvNames[name]; ok {
+		return key
+	}
+	return strings.ReplaceAll(strings.ToUpper(name), "-", "_")
+}
+
+// flagName returns the command-line flag for a config key, the inverse of
+// configKey. Keys that are no environment variable map to a flag whose
+// configKey differs from the key.
+func flagName(key string) string {
+	for name, env := range flagEnvNames {
+		if env == key {
+			return name
+		}
+	}
+	return strings.ReplaceAll(strings.ToLower(key), "_", "-")
+}
+
+// applyConfigFile sets flags from config file values. Settings given on the
+// command line or via environment variables take precedence; their keys are
+// returned as pinned so reloads leave them alone too, even when they are only
+// added to the file later.
+func applyConfigFile(fs *pflag.FlagSet, values map[string]string) (map[string]bool, error) {
+	pinned := make(map[string]bool)
+	fs.VisitAll(func(f *pflag.Flag) {
+		if key := configKey(f.Name); f.Changed || lookupEnv(key) != "" {
+			pinned[key] = true
+		}
+	})
+
+	for key, value := range values {
+		name := flagName(key)
+		if fs.Lookup(name) == nil || configKey(name) != key || name == "config-file" || name == "version" {
+			return nil, fmt.Errorf("unknown setting %s in config file", key)
+		}
+		if pinned[key] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return nil, fmt.Errorf("invalid value for %s in config file: %w", key, err)
+		}
+	}
+	return pinned, nil
+}
+
+// Reload re-reads the config file and returns a copy of c with the
+// hot-reloadable settings (log level, cache TTL, skip-inactive) updated.
+// Settings that can only change with a restart are returned as ignored when
+// their value in the file changed. Settings given on the command line or via
+// environment variables keep their value.
+func (c *Config) Reload() (reloaded *Config, ignored []string, err error) {
+	if c.ConfigFile == "" {
+		return c, nil, nil
+	}
+
+	values, err := readConfigFile(c.ConfigFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	next := *c
+	next.fileValues = values
+	for key := range unionKeys(c.fileValues, values) {
+		if c.pinned[key] || values[key] == c.fileValues[key] {
+			continue
+		}
+		if !reloadableKeys[key] {
+			ignored = append(ignored, key)
+			continue
+		}
+		if err := next.setReloadable(key, values[key]); err != nil {
+			return nil, nil, fmt.Errorf("invalid value for %s in config file: %w", key, err)
+		}
+	}
+	return &next, ignored, nil
+}
+
+// setReloadable applies a hot-reloadable setting; an empty value (setting
+// removed from the file) restores the default
+func (c *Config) setReloadable(key, value string) error {
+	switch key {
+	case "LOG_LEVEL":
+		if value == "" {
+			value = "info"
+		}
+		c.LogLevel = value
+	case "CACHE_TTL":
+		seconds := 0
+		if value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				return fmt.Errorf("must be a non-negative number of seconds, got %q", value)
+			}
+			seconds = parsed
+		}
+		c.CacheTTL = time.Duration(seconds) * time.Second
+	case "SKIP_INACTIVE":
+		skip := false
+		if value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("must be a boolean, got %q", value)
+			}
+			skip = parsed
+		}
+		c.SkipInactive = skip
+	}
+	return nil
+}
+
+// unionKeys returns the set of keys present in either map
+func unionKeys(a, b map[string]string) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
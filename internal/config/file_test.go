@@ -0,0 +1,178 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// writeConfigFile writes content to path, failing the test on error
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.conf")
+	writeConfigFile(t, path, `# exporter settings
+HETZNER_TOKEN=file-token
+LOG_LEVEL=warn
+CACHE_TTL=60
+LISTEN_ADDRESS=":9600"
+`)
+	t.Setenv("LOG_LEVEL", "error")
+	resetFlags("--config-file="+path, "--listen-address=:9700")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+	if cfg.HetznerToken != "file-token" {
+		t.Errorf("Load() HetznerToken = %v, want file-token", cfg.HetznerToken)
+	}
+	if cfg.CacheTTL != 60*time.Second {
+		t.Errorf("Load() CacheTTL = %v, want 60s", cfg.CacheTTL)
+	}
+	// Environment variables and flags take precedence over the file
+	if cfg.LogLevel != "error" {
+		t.Errorf("Load() LogLevel = %v, want error from env", cfg.LogLevel)
+	}
+	if cfg.ListenAddress != ":9700" {
+		t.Errorf("Load() ListenAddress = %v, want :9700 from flag", cfg.ListenAddress)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "unknown setting", content: "HETZNER_TOKEN=x\nNOT_A_SETTING=1\n"},
+		{name: "invalid value", content: "HETZNER_TOKEN=x\nCACHE_TTL=soon\n"},
+		{name: "malformed line", content: "HETZNER_TOKEN=x\nCACHE_TTL\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "exporter.conf")
+			writeConfigFile(t, path, tt.content)
+			resetFlags("--config-file=" + path)
+
+			if _, err := Load(); err == nil {
+				t.Error("Load() expected error but got none")
+			}
+		})
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.conf")
+	writeConfigFile(t, path, "HETZNER_TOKEN=file-token\nLOG_LEVEL=info\nCACHE_TTL=30\n")
+	t.Setenv("SKIP_INACTIVE", "true")
+	resetFlags("--config-file=" + path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+
+	writeConfigFile(t, path, "HETZNER_TOKEN=file-token\nLOG_LEVEL=debug\nCACHE_TTL=120\nLISTEN_ADDRESS=:9700\nSKIP_INACTIVE=false\n")
+	reloaded, ignored, err := cfg.Reload()
+	if err != nil {
+		t.Fatalf("Reload() unexpected error = %v", err)
+	}
+
+	if reloaded.LogLevel != "debug" {
+		t.Errorf("Reload() LogLevel = %v, want debug", reloaded.LogLevel)
+	}
+	if reloaded.CacheTTL != 120*time.Second {
+		t.Errorf("Reload() CacheTTL = %v, want 2m0s", reloaded.CacheTTL)
+	}
+	if reloaded.ListenAddress != cfg.ListenAddress {
+		t.Errorf("Reload() changed ListenAddress to %v", reloaded.ListenAddress)
+	}
+	if len(ignored) != 1 || ignored[0] != "LISTEN_ADDRESS" {
+		t.Errorf("Reload() ignored = %v, want [LISTEN_ADDRESS]", ignored)
+	}
+	// Settings from the environment are not overridden by the file
+	if !reloaded.SkipInactive {
+		t.Error("Reload() overrode SKIP_INACTIVE set via environment")
+	}
+	// The original config is left untouched
+	if cfg.LogLevel != "info" {
+		t.Errorf("Reload() modified the original config LogLevel to %v", cfg.LogLevel)
+	}
+
+	writeConfigFile(t, path, "CACHE_TTL=-5\n")
+	if _, _, err := reloaded.Reload(); err == nil {
+		t.Error("Reload() expected error for an invalid cache TTL")
+	}
+}
+
+func TestLoadConfigFileEnvNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "plural env var name", content: "HETZNER_TOKEN=x\nEXPORT_LABELS=team,env\n", want: []string{"team", "env"}},
+		{name: "env var wins over the file", content: "HETZNER_TOKEN=x\nEXPORT_LABELS=fromfile\n", env: "fromenv", want: []string{"fromenv"}},
+		{name: "flag-derived name is unknown", content: "HETZNER_TOKEN=x\nEXPORT_LABEL=team\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "exporter.conf")
+			writeConfigFile(t, path, tt.content)
+			if tt.env != "" {
+				t.Setenv("EXPORT_LABELS", tt.env)
+			}
+			resetFlags("--config-file=" + path)
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Load() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+			if strings.Join(cfg.ExportLabels, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Load() ExportLabels = %v, want %v", cfg.ExportLabels, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigKeysMatchEnvVars(t *testing.T) {
+	t.Setenv("HETZNER_TOKEN", "x")
+	resetFlags()
+	if _, err := Load(); err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+
+	envVar := regexp.MustCompile(`via (\w+) env var`)
+	pflag.CommandLine.VisitAll(func(f *pflag.Flag) {
+		match := envVar.FindStringSubmatch(f.Usage)
+		if match == nil {
+			return
+		}
+		if got := configKey(f.Name); got != match[1] {
+			t.Errorf("config key of --%s = %s, want its env var %s", f.Name, got, match[1])
+		}
+		if got := flagName(match[1]); got != f.Name {
+			t.Errorf("flag of config key %s = --%s, want --%s", match[1], got, f.Name)
+		}
+	})
+}
@@ -2,33 +2,101 @@ package hetzner
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/cache"
+	"golang.org/x/time/rate"
 )
 
 const (
 	defaultBaseURL = "https://api.hetzner.com/v1"
-	defaultTimeout = 30 * time.Second
+	defaultPerPage = 50 // Maximum page size allowed by the Hetzner API
+
+	defaultAuthScheme = "Bearer"
+
+	// defaultMaxPages caps pagination against an API that never stops
+	// advertising a next page
+	defaultMaxPages = 100
+
+	// defaultRetryBaseDelay is the wait before the first retry, doubled for
+	// each further retry
+	defaultRetryBaseDelay = 500 * time.Millisecond
+
+	// defaultMaxRetryAfter caps the wait requested by a Retry-After header
+	defaultMaxRetryAfter = 60 * time.Second
+
+	// maxCapturedBodyBytes bounds how much of a response body that failed to
+	// decode is logged for debugging
+	maxCapturedBodyBytes = 512
 )
 
 // Client is a Hetzner API client for Storage Boxes
 type Client struct {
-	httpClient *http.Client
-	token      string
-	baseURL    string
+	httpClient            *http.Client
+	transport             *http.Transport
+	tokenMu               sync.RWMutex
+	token                 string
+	authScheme            string
+	tokenGeneration       int64
+	baseURL               string
+	paginationConcurrency int
+	maxPages              int
+	limiter               *rate.Limiter
+	maxAttempts           int
+	retryBaseDelay        time.Duration
+	maxRetryAfter         time.Duration
+	partialPagination     bool
+	successStatusCodes    map[int]bool
 }
 
-// NewClient creates a new Hetzner API client
+// NewClient creates a new Hetzner API client with its own transport
 func NewClient(token string) *Client {
+	return NewClientWithTransport(token, NewTransport(0))
+}
+
+// NewTransport creates an HTTP transport tuned for the Hetzner API that may be
+// shared by several clients. maxConnsPerHost bounds the connections per host
+// across all clients sharing it, 0 for no limit.
+func NewTransport(maxConnsPerHost int) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = maxConnsPerHost
+	return transport
+}
+
+// NewClientWithTransport creates a new Hetzner API client using the given
+// transport, allowing several clients to share one connection pool. Transport
+// settings such as SetForceHTTP1 then apply to every client sharing it.
+// Requests have no timeout of their own; they are bounded by the deadline of
+// the context passed to the client's methods.
+func NewClientWithTransport(token string, transport *http.Transport) *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: defaultTimeout,
+			Transport: transport,
 		},
-		token:   token,
-		baseURL: defaultBaseURL,
+		transport:             transport,
+		token:                 token,
+		authScheme:            defaultAuthScheme,
+		baseURL:               defaultBaseURL,
+		paginationConcurrency: 1,
+		maxPages:              defaultMaxPages,
+		maxAttempts:           1,
+		retryBaseDelay:        defaultRetryBaseDelay,
+		maxRetryAfter:         defaultMaxRetryAfter,
+		successStatusCodes:    map[int]bool{http.StatusOK: true},
 	}
 }
 
@@ -37,6 +105,82 @@ func (c *Client) SetBaseURL(url string) {
 	c.baseURL = url
 }
 
+// BaseURL returns the base URL requests are sent to, with any credentials,
+// query and fragment removed so it is safe to log or expose. An unparsable
+// base URL yields an empty string.
+func (c *Client) BaseURL() string {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
+
+// CacheKey returns the key under which responses for this client's account and
+// endpoint are cached. It never contains the raw token.
+func (c *Client) CacheKey() string {
+	return cache.Key(c.Token(), c.baseURL)
+}
+
+// SetToken replaces the API token used for subsequent requests, e.g. after the
+// token file was rotated. Requests already in flight keep the previous token.
+func (c *Client) SetToken(token string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+	c.token = token
+	c.tokenGeneration++
+}
+
+// TokenGeneration returns how many times the token has been replaced via
+// SetToken, 0 while the initial token is in use
+func (c *Client) TokenGeneration() int64 {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.tokenGeneration
+}
+
+// SetAuthScheme sets the scheme of the Authorization header, e.g. Token for a
+// gateway in front of the API that expects it. An empty scheme keeps Bearer.
+func (c *Client) SetAuthScheme(scheme string) {
+	if scheme == "" {
+		scheme = defaultAuthScheme
+	}
+	c.authScheme = scheme
+}
+
+// authorization returns the Authorization header value for the current token
+func (c *Client) authorization() string {
+	return fmt.Sprintf("%s %s", c.authScheme, c.Token())
+}
+
+// Token returns the API token currently in use
+func (c *Client) Token() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.token
+}
+
+// SetPaginationConcurrency sets how many pages may be fetched in parallel once
+// the total page count is known. Values below 1 fall back to sequential fetching.
+func (c *Client) SetPaginationConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.paginationConcurrency = n
+}
+
+// SetMaxPages sets how many pages ListStorageBoxes fetches at most before it
+// stops paginating with a warning. Values below 1 keep the default.
+func (c *Client) SetMaxPages(n int) {
+	if n < 1 {
+		n = defaultMaxPages
+	}
+	c.maxPages = n
+}
+
 // StorageBox represents a Hetzner Storage Box
 type StorageBox struct {
 	ID             int64             `json:"id"`
@@ -66,14 +210,14 @@ type Location struct {
 // StorageBoxType represents the type of storage box
 type StorageBoxType struct {
 	Name string `json:"name"`
-	Size int64  `json:"size"` // Total quota/capacity in bytes
+	Size Int64  `json:"size"` // Total quota/capacity in bytes
 }
 
 // Stats represents storage usage statistics
 type Stats struct {
-	Size          int64 `json:"size"`           // Total usage in bytes
-	SizeData      int64 `json:"size_data"`      // Data usage in bytes
-	SizeSnapshots int64 `json:"size_snapshots"` // Snapshot usage in bytes
+	Size          Int64 `json:"size"`           // Total usage in bytes
+	SizeData      Int64 `json:"size_data"`      // Data usage in bytes
+	SizeSnapshots Int64 `json:"size_snapshots"` // Snapshot usage in bytes
 }
 
 // AccessSettings represents the access configuration
@@ -87,7 +231,33 @@ type AccessSettings struct {
 
 // SnapshotPlan represents the automatic snapshot configuration
 type SnapshotPlan struct {
-	Enabled bool `json:"enabled"`
+	Enabled      bool `json:"enabled"`
+	MaxSnapshots int  `json:"max_snapshots"` // Snapshots kept before the oldest is deleted
+	Minute       int  `json:"minute"`
+	Hour         int  `json:"hour"`
+	DayOfWeek    *int `json:"day_of_week"`  // 1 (Monday) to 7 (Sunday), nil for every day
+	DayOfMonth   *int `json:"day_of_month"` // 1 to 31, nil for every day
+}
+
+// Snapshot represents a snapshot of a storage box
+type Snapshot struct {
+	ID          int64         `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Stats       SnapshotStats `json:"stats"`
+	IsAutomatic bool          `json:"is_automatic"`
+	Created     time.Time     `json:"created"`
+}
+
+// SnapshotStats represents the disk usage of a snapshot
+type SnapshotStats struct {
+	Size           Int64 `json:"size"`            // Diskspace used by the snapshot in bytes
+	SizeFilesystem Int64 `json:"size_filesystem"` // Size of the snapshotted filesystem in bytes
+}
+
+// snapshotsResponse represents the API response for listing snapshots
+type snapshotsResponse struct {
+	Snapshots []Snapshot `json:"snapshots"`
 }
 
 // Protection represents the protection settings
@@ -98,64 +268,596 @@ type Protection struct {
 // storageBoxesResponse represents the API response for listing storage boxes
 type storageBoxesResponse struct {
 	StorageBoxes []StorageBox `json:"storage_boxes"`
+	Meta         responseMeta `json:"meta"`
+}
+
+// responseMeta represents the meta object of list responses
+type responseMeta struct {
+	Pagination *pagination `json:"pagination"`
+}
+
+// pagination represents the pagination details of list responses.
+// LastPage and NextPage are nil when unknown or when there are no more pages.
+type pagination struct {
+	Page     int  `json:"page"`
+	PerPage  int  `json:"per_page"`
+	NextPage *int `json:"next_page"`
+	LastPage *int `json:"last_page"`
+}
+
+// SetForceHTTP1 pins the transport to HTTP/1.1, working around proxies that
+// misbehave with HTTP/2. By default HTTP/2 is negotiated automatically.
+func (c *Client) SetForceHTTP1(force bool) {
+	if force {
+		c.transport.ForceAttemptHTTP2 = false
+		// A non-nil, empty map disables the HTTP/2 upgrade via ALPN
+		c.transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		return
+	}
+	c.transport.ForceAttemptHTTP2 = true
+	c.transport.TLSNextProto = nil
+}
+
+// SetTransportTimeouts sets the connect, TLS handshake and response header
+// timeouts of the transport, independent of the overall request timeout, so
+// unreachable endpoints fail fast while slow bodies are still allowed. Values
+// of 0 or below keep Go's defaults.
+func (c *Client) SetTransportTimeouts(dial, tlsHandshake, responseHeader time.Duration) {
+	if dial > 0 {
+		dialer := &net.Dialer{Timeout: dial, KeepAlive: 30 * time.Second}
+		c.transport.DialContext = dialer.DialContext
+	}
+	if tlsHandshake > 0 {
+		c.transport.TLSHandshakeTimeout = tlsHandshake
+	}
+	if responseHeader > 0 {
+		c.transport.ResponseHeaderTimeout = responseHeader
+	}
+}
+
+// SetRateLimit limits outgoing API requests to the given number of requests
+// per second using a token bucket. Values of 0 or below disable the limiter.
+func (c *Client) SetRateLimit(requestsPerSecond float64) {
+	if requestsPerSecond <= 0 {
+		c.limiter = nil
+		return
+	}
+	c.limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), 1)
+}
+
+// SetMaxAttempts sets how many times a request failing with a retryable error
+// (rate limit or server error) is attempted in total. Values below 1 fall back
+// to a single attempt without retries.
+func (c *Client) SetMaxAttempts(n int) {
+	if n < 1 {
+		n = 1
+	}
+	c.maxAttempts = n
+}
+
+// SetRetryBaseDelay sets the wait before the first retry. Each further retry
+// waits twice as long as the previous one, with jitter so clients retrying at
+// the same time spread out. Negative values are treated as 0, retrying
+// immediately.
+func (c *Client) SetRetryBaseDelay(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	c.retryBaseDelay = d
+}
+
+// SetMaxRetryAfter caps how long a retry waits when the API asks for a longer
+// wait with a Retry-After header, so a buggy or hostile server cannot stall
+// scrapes. 0 or below ignores Retry-After and always uses the backoff.
+func (c *Client) SetMaxRetryAfter(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	c.maxRetryAfter = d
+}
+
+// SetPartialPagination controls what happens when a page after the first one
+// fails: by default ListStorageBoxes fails as a whole, with partial pagination
+// it returns the storage boxes fetched so far together with a
+// *PartialResultError.
+func (c *Client) SetPartialPagination(allow bool) {
+	c.partialPagination = allow
+}
+
+// SetSuccessStatusCodes sets the HTTP status codes whose responses are
+// decoded as storage box listings, e.g. 203 from caching proxies. An empty
+// list keeps the default of 200 only.
+func (c *Client) SetSuccessStatusCodes(codes []int) {
+	if len(codes) == 0 {
+		codes = []int{http.StatusOK}
+	}
+	c.successStatusCodes = make(map[int]bool, len(codes))
+	for _, code := range codes {
+		c.successStatusCodes[code] = true
+	}
+}
+
+// RequestStats collects statistics about the API requests made on behalf of a
+// context, e.g. a single scrape. It is safe for concurrent use.
+type RequestStats struct {
+	retries      atomic.Int64
+	payloadBytes atomic.Int64
+	truncated    atomic.Bool
+	deprecated   atomic.Bool
+}
+
+// Retries returns the number of retried requests
+func (s *RequestStats) Retries() int64 {
+	return s.retries.Load()
+}
+
+// PayloadBytes returns the number of response body bytes read from successful
+// responses
+func (s *RequestStats) PayloadBytes() int64 {
+	return s.payloadBytes.Load()
+}
+
+// Truncated reports whether pagination stopped at the page limit before the
+// last page
+func (s *RequestStats) Truncated() bool {
+	return s.truncated.Load()
+}
+
+// Deprecated reports whether an API response announced the deprecation of the
+// endpoint through a Deprecation or Sunset header or a deprecation warning
+func (s *RequestStats) Deprecated() bool {
+	return s.deprecated.Load()
+}
+
+// countingReader counts the bytes read through it and keeps the first
+// maxCapturedBodyBytes of them
+type countingReader struct {
+	r    io.Reader
+	n    int64
+	head []byte
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if room := maxCapturedBodyBytes - len(c.head); room > 0 {
+		c.head = append(c.head, p[:min(n, room)]...)
+	}
+	return n, err
+}
+
+// recordDeprecation records and logs deprecation signals in the response
+// headers: Deprecation (RFC 9745), Sunset (RFC 8594) and deprecation warnings
+// in Warning. Each signal is logged once per request context.
+func recordDeprecation(ctx context.Context, header http.Header, path string) {
+	deprecation, sunset, warning := header.Get("Deprecation"), header.Get("Sunset"), deprecationWarning(header)
+	if deprecation == "" && sunset == "" && warning == "" {
+		return
+	}
+	if requestStatsFrom(ctx).deprecated.Swap(true) {
+		return
+	}
+	slog.Warn("Hetzner API announced a deprecation",
+		"path", path,
+		"deprecation", deprecation,
+		"sunset", sunset,
+		"warning", warning,
+	)
+}
+
+// warningPattern matches a warning of a Warning header (RFC 7234): its code,
+// agent and quoted text
+var warningPattern = regexp.MustCompile(`(\d{3}) \S+ "((?:[^"\\]|\\.)*)"`)
+
+// deprecationWarning returns the first warning in the Warning headers that
+// announces a deprecation: a persistent warning (code 299) whose text mentions
+// a deprecation or sunset. Caching warnings such as 110 (Response is Stale),
+// 113 (Heuristic Expiration) or 214 (Transformation Applied) are ignored.
+func deprecationWarning(header http.Header) string {
+	for _, value := range header.Values("Warning") {
+		for _, match := range warningPattern.FindAllStringSubmatch(value, -1) {
+			text := strings.ToLower(match[2])
+			if match[1] == "299" && (strings.Contains(text, "deprecat") || strings.Contains(text, "sunset")) {
+				return match[0]
+			}
+		}
+	}
+	return ""
 }
 
-// ListStorageBoxes retrieves all storage boxes from the Hetzner API
+// redactToken masks every occurrence of token in s
+func redactToken(s, token string) string {
+	if token == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, token, "[REDACTED]")
+}
+
+type requestStatsKey struct{}
+
+// WithRequestStats returns a context whose API requests are recorded in stats
+func WithRequestStats(ctx context.Context, stats *RequestStats) context.Context {
+	return context.WithValue(ctx, requestStatsKey{}, stats)
+}
+
+// requestStatsFrom returns the stats attached to ctx, or a throwaway instance
+func requestStatsFrom(ctx context.Context) *RequestStats {
+	if stats, ok := ctx.Value(requestStatsKey{}).(*RequestStats); ok {
+		return stats
+	}
+	return &RequestStats{}
+}
+
+// Warmup sends a cheap HEAD request to the API so that DNS resolution, the
+// TCP connection and the TLS handshake happen before the first real request,
+// which then reuses the pooled connection. Any HTTP response counts as
+// success, only transport failures are returned.
+func (c *Client) Warmup(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.baseURL+"/storage_boxes?per_page=1", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", c.authorization())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	// Drain the body so the connection returns to the pool
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// ListStorageBoxes retrieves all storage boxes from the Hetzner API, following
+// pagination until every page has been fetched or the page limit is reached
 func (c *Client) ListStorageBoxes(ctx context.Context) ([]StorageBox, error) {
-	url := fmt.Sprintf("%s/storage_boxes", c.baseURL)
+	result, err := c.fetchStorageBoxesPage(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	boxes := result.StorageBoxes
+
+	p := result.Meta.Pagination
+	if p == nil || p.NextPage == nil {
+		return boxes, nil
+	}
+
+	// With a known last page the remaining pages can be fetched in parallel
+	if c.paginationConcurrency > 1 && p.LastPage != nil {
+		last := *p.LastPage
+		if last > c.maxPages {
+			c.truncatePagination(ctx)
+			last = c.maxPages
+		}
+		rest, err := c.fetchPagesConcurrently(ctx, *p.NextPage, last)
+		if err != nil {
+			return c.paginationFailed(append(boxes, rest...), err)
+		}
+		return append(boxes, rest...), nil
+	}
+
+	for pages, next := 1, p.NextPage; next != nil; pages++ {
+		if pages >= c.maxPages {
+			c.truncatePagination(ctx)
+			break
+		}
+		result, err := c.fetchStorageBoxesPage(ctx, *next)
+		if err != nil {
+			return c.paginationFailed(boxes, budgetExhausted(ctx, *next, err))
+		}
+		boxes = append(boxes, result.StorageBoxes...)
+
+		next = nil
+		if result.Meta.Pagination != nil {
+			next = result.Meta.Pagination.NextPage
+		}
+	}
+
+	return boxes, nil
+}
+
+// ListSnapshots retrieves the snapshots of the storage box with the given ID.
+// A box that no longer exists fails with an *APIError with status 404.
+func (c *Client) ListSnapshots(ctx context.Context, id int64) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	err := c.withRetries(ctx, func() error {
+		resp, err := c.get(ctx, fmt.Sprintf("%s/storage_boxes/%d/snapshots", c.baseURL, id))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		body := &countingReader{r: resp.Body}
+		var result snapshotsResponse
+		err = json.NewDecoder(body).Decode(&result)
+		requestStatsFrom(ctx).payloadBytes.Add(body.n)
+		if err != nil {
+			return fmt.Errorf("failed to decode snapshots of storage box %d: %w", id, err)
+		}
+		if result.Snapshots == nil {
+			return fmt.Errorf("%w: missing snapshots key", ErrUnexpectedResponse)
+		}
+		snapshots = result.Snapshots
+		return nil
+	})
+	return snapshots, err
+}
+
+// truncatePagination records that pagination stopped at the page limit
+func (c *Client) truncatePagination(ctx context.Context) {
+	requestStatsFrom(ctx).truncated.Store(true)
+	slog.Warn("Stopped paginating at the page limit, storage boxes on later pages are missing",
+		"max_pages", c.maxPages,
+	)
+}
+
+// budgetExhausted reports a page that failed because the deadline shared by
+// all pages ran out as ErrTimeoutBudgetExhausted, so a slow early page does not
+// surface as an unexplained failure of a later one. Other errors are returned
+// unchanged.
+func budgetExhausted(ctx context.Context, page int, err error) error {
+	if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: page %d not fetched in time: %w", ErrTimeoutBudgetExhausted, page, err)
+}
+
+// paginationFailed applies the partial pagination policy after a page other
+// than the first failed, with boxes holding the storage boxes fetched so far
+func (c *Client) paginationFailed(boxes []StorageBox, err error) ([]StorageBox, error) {
+	if !c.partialPagination {
+		return nil, err
+	}
+	return boxes, &PartialResultError{Err: err}
+}
+
+// fetchPagesConcurrently fetches pages first..last with at most
+// paginationConcurrency requests in flight. The first failure is returned
+// together with the storage boxes of the pages that succeeded; unless partial
+// pagination is allowed it also cancels the remaining requests.
+func (c *Client) fetchPagesConcurrently(ctx context.Context, first, last int) ([]StorageBox, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		boxes    []StorageBox
+		firstErr error
+	)
+	sem := make(chan struct{}, c.paginationConcurrency)
+
+dispatch:
+	for page := first; page <= last; page++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func(page int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, err := c.fetchStorageBoxesPage(ctx, page)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = budgetExhausted(ctx, page, err)
+					if !c.partialPagination {
+						cancel()
+					}
+				}
+				return
+			}
+			boxes = append(boxes, result.StorageBoxes...)
+		}(page)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return boxes, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("pagination aborted: %w", err)
+	}
+	return boxes, nil
+}
+
+// fetchStorageBoxesPage retrieves a single page of storage boxes, retrying
+// retryable errors up to maxAttempts attempts in total with exponential backoff
+func (c *Client) fetchStorageBoxesPage(ctx context.Context, page int) (*storageBoxesResponse, error) {
+	var result *storageBoxesResponse
+	err := c.withRetries(ctx, func() error {
+		var err error
+		result, err = c.doFetchStorageBoxesPage(ctx, page)
+		return err
+	})
+	return result, err
+}
+
+// withRetries calls do until it succeeds, fails with an error that is not
+// retryable or maxAttempts attempts have been made
+func (c *Client) withRetries(ctx context.Context, do func() error) error {
+	stats := requestStatsFrom(ctx)
+
+	var lastErr error
+	for attempt := 1; attempt <= c.maxAttempts; attempt++ {
+		if attempt > 1 {
+			if err := c.waitBeforeRetry(ctx, attempt-1, lastErr); err != nil {
+				return fmt.Errorf("retry aborted: %w", err)
+			}
+			stats.retries.Add(1)
+		}
+
+		err := do()
+		if err == nil || !IsRetryableError(err) {
+			return err
+		}
+		lastErr = err
+	}
+	return lastErr
+}
+
+// get sends a GET request to url and returns the response if its status code
+// is a success status code. Other status codes are returned as *APIError
+// with the body already closed.
+func (c *Client) get(ctx context.Context, url string) (*http.Response, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("rate limiter wait failed: %w", err)
+		}
+	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	req.Header.Set("Authorization", c.authorization())
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute request: %w", err)
 	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
+	recordDeprecation(ctx, resp.Header, req.URL.Path)
 
-	if resp.StatusCode != http.StatusOK {
-		// Extract request ID from response headers if available
-		requestID := resp.Header.Get("X-Request-Id")
-		if requestID == "" {
-			requestID = resp.Header.Get("X-Amzn-Requestid") // Alternative header
-		}
+	if !c.successStatusCodes[resp.StatusCode] {
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, NewAPIErrorWithWrap(resp.StatusCode, "API request failed: failed to read response body", requestID, err)
-		}
+// responseError builds the *APIError for a response with an unsuccessful
+// status code from its body and headers
+func responseError(resp *http.Response) error {
+	// Extract request ID from response headers if available
+	requestID := resp.Header.Get("X-Request-Id")
+	if requestID == "" {
+		requestID = resp.Header.Get("X-Amzn-Requestid") // Alternative header
+	}
 
-		// Try to parse JSON error message from Hetzner API
-		var errorResponse struct {
-			Error struct {
-				Message string `json:"message"`
-				Code    string `json:"code"`
-			} `json:"error"`
-		}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return NewAPIErrorWithWrap(resp.StatusCode, "API request failed: failed to read response body", requestID, err)
+	}
+
+	// Try to parse JSON error message from Hetzner API
+	var errorResponse struct {
+		Error struct {
+			Message string          `json:"message"`
+			Code    string          `json:"code"`
+			Details json.RawMessage `json:"details"`
+		} `json:"error"`
+	}
 
-		message := fmt.Sprintf("HTTP %d error", resp.StatusCode)
-		if len(body) > 0 {
-			if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Error.Message != "" {
-				message = errorResponse.Error.Message
-			} else {
-				message = string(body)
+	message := fmt.Sprintf("HTTP %d error", resp.StatusCode)
+	if len(body) > 0 {
+		if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Error.Message != "" {
+			message = errorResponse.Error.Message
+			if details := summarizeErrorDetails(errorResponse.Error.Details); details != "" {
+				message = fmt.Sprintf("%s (details: %s)", message, details)
 			}
+		} else {
+			message = string(body)
 		}
+	}
 
-		return nil, NewAPIError(resp.StatusCode, message, requestID)
+	apiErr := NewAPIError(resp.StatusCode, message, requestID)
+	apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	return apiErr
+}
+
+// waitBeforeRetry waits before the given retry, counting from 1, or until ctx
+// is done. The wait is the one requested by a Retry-After header of the
+// failed request, capped at maxRetryAfter, or else a random duration between
+// half and all of retryBaseDelay*2^(retry-1).
+func (c *Client) waitBeforeRetry(ctx context.Context, retry int, lastErr error) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+	delay := retryBackoff(c.retryBaseDelay, retry)
+	var apiErr *APIError
+	if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > 0 && c.maxRetryAfter > 0 {
+		delay = apiErr.RetryAfter
+		if delay > c.maxRetryAfter {
+			slog.Warn("Clamping Retry-After requested by the Hetzner API",
+				"retry_after", apiErr.RetryAfter,
+				"max_retry_after", c.maxRetryAfter,
+			)
+			delay = c.maxRetryAfter
+		}
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
+// retryBackoff returns the jittered exponential backoff for the given retry
+func retryBackoff(base time.Duration, retry int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	// Cap the exponent so the shift cannot overflow
+	delay := base << min(retry-1, 16)
+	if delay <= 0 {
+		delay = base
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// doFetchStorageBoxesPage performs a single request for a page of storage boxes
+func (c *Client) doFetchStorageBoxesPage(ctx context.Context, page int) (*storageBoxesResponse, error) {
+	url := fmt.Sprintf("%s/storage_boxes?page=%d&per_page=%d", c.baseURL, page, defaultPerPage)
+	resp, err := c.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body := &countingReader{r: resp.Body}
 	var result storageBoxesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	err = json.NewDecoder(body).Decode(&result)
+	requestStatsFrom(ctx).payloadBytes.Add(body.n)
+	if err != nil {
+		// The body is lost once decoding failed, so keep its start for
+		// diagnosing proxies or API changes. The token is masked in case a
+		// misbehaving proxy echoes the request.
+		slog.Debug("Failed to decode API response",
+			"url", url,
+			"status_code", resp.StatusCode,
+			"body_bytes_read", body.n,
+			"body_head", redactToken(string(body.head), c.Token()),
+			"error", err,
+		)
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	return result.StorageBoxes, nil
+	// An explicit empty array is an empty account, a missing key is not
+	if result.StorageBoxes == nil {
+		return nil, fmt.Errorf("%w: missing storage_boxes key", ErrUnexpectedResponse)
+	}
+
+	return &result, nil
 }
@@ -1,8 +1,13 @@
 package hetzner
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // APIError represents a typed API error from Hetzner Cloud API
@@ -11,6 +16,8 @@ type APIError struct {
 	Message    string `json:"message"`
 	RequestID  string `json:"request_id,omitempty"`
 	Err        error  `json:"-"`
+	// RetryAfter is the wait requested by the Retry-After header, 0 if none
+	RetryAfter time.Duration `json:"-"`
 }
 
 // Error implements the error interface
@@ -86,6 +93,27 @@ func NewAPIErrorWithWrap(statusCode int, message string, requestID string, err e
 	}
 }
 
+// parseRetryAfter parses a Retry-After header given as delay in seconds or as
+// HTTP date. Missing, invalid and past values yield 0.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		// Cap absurd values so the multiplication cannot overflow
+		return time.Duration(min(seconds, int64(24*time.Hour/time.Second))) * time.Second
+	}
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+	return date.Sub(now)
+}
+
 // IsAPIError checks if the error is an APIError
 func IsAPIError(err error) bool {
 	_, ok := err.(*APIError)
@@ -152,3 +180,80 @@ func IsServerError(err error) bool {
 	}
 	return false
 }
+
+// ErrUnexpectedResponse is returned when a successful response does not have
+// the expected shape, e.g. after an API change or when a proxy answers for a
+// different endpoint
+var ErrUnexpectedResponse = errors.New("unexpected API response")
+
+// ErrTimeoutBudgetExhausted is returned when the deadline for listing storage
+// boxes runs out after the first page, e.g. because an early page was slow.
+// It wraps the error of the page that could not be fetched in time.
+var ErrTimeoutBudgetExhausted = errors.New("timeout budget exhausted mid-pagination")
+
+// PartialResultError is returned together with the storage boxes fetched so
+// far when a page after the first one fails and partial results are allowed
+// via SetPartialPagination
+type PartialResultError struct {
+	Err error
+}
+
+// Error implements the error interface
+func (e *PartialResultError) Error() string {
+	return fmt.Sprintf("incomplete pagination: %v", e.Err)
+}
+
+// Unwrap returns the error of the failed page
+func (e *PartialResultError) Unwrap() error {
+	return e.Err
+}
+
+// errorDetail is a field-level message from the details of a Hetzner error
+type errorDetail struct {
+	Name     string   `json:"name"`
+	Field    string   `json:"field"`
+	Message  string   `json:"message"`
+	Messages []string `json:"messages"`
+}
+
+// summarizeErrorDetails renders the details of a Hetzner error object as a
+// concise "field: message" list. Hetzner wraps field errors in an object
+// ({"fields": [...]}), but a bare array of entries is accepted as well.
+// Unrecognized details yield an empty summary.
+func summarizeErrorDetails(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var entries []errorDetail
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		var wrapped struct {
+			Fields []errorDetail `json:"fields"`
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil {
+			return ""
+		}
+		entries = wrapped.Fields
+	}
+
+	parts := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name
+		if name == "" {
+			name = entry.Field
+		}
+		messages := entry.Messages
+		if entry.Message != "" {
+			messages = append([]string{entry.Message}, messages...)
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		if name == "" {
+			parts = append(parts, strings.Join(messages, ", "))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", name, strings.Join(messages, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,47 @@
+package hetzner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Int64 is an int64 that decodes from JSON numbers as well as string-encoded
+// integers, since some APIs switch large numbers to strings or floats to avoid
+// precision loss in JavaScript clients
+type Int64 int64
+
+// UnmarshalJSON implements json.Unmarshaler
+func (i *Int64) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	raw := string(data)
+	if unquoted, err := strconv.Unquote(raw); err == nil {
+		raw = unquoted
+	}
+
+	if parsed, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		*i = Int64(parsed)
+		return nil
+	}
+
+	// Accept floats such as 1.099511627776e+12 as long as they hold an integer
+	// within the int64 range. float64(math.MaxInt64) rounds up to 2^63, which
+	// is already out of range.
+	parsed, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(parsed) || math.IsInf(parsed, 0) ||
+		parsed >= math.MaxInt64 || parsed < math.MinInt64 || parsed != math.Trunc(parsed) {
+		return fmt.Errorf("invalid integer value %s", data)
+	}
+	*i = Int64(parsed)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, always encoding a JSON number
+func (i Int64) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int64(i))
+}
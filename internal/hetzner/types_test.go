@@ -0,0 +1,74 @@
+package hetzner
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestInt64UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Int64
+		wantErr bool
+	}{
+		{name: "number", input: `1099511627776`, want: 1099511627776},
+		{name: "string", input: `"1099511627776"`, want: 1099511627776},
+		{name: "integral float", input: `1.099511627776e+12`, want: 1099511627776},
+		{name: "string float", input: `"1099511627776.0"`, want: 1099511627776},
+		{name: "null keeps zero value", input: `null`, want: 0},
+		{name: "fractional float", input: `1.5`, wantErr: true},
+		{name: "negative integral float", input: `-1.5e+3`, want: -1500},
+		{name: "smallest int64 as float", input: `-9.223372036854775808e+18`, want: math.MinInt64},
+		{name: "fractional string float", input: `"2.5"`, wantErr: true},
+		{name: "NaN string", input: `"NaN"`, wantErr: true},
+		{name: "infinity string", input: `"+Inf"`, wantErr: true},
+		{name: "negative infinity string", input: `"-Inf"`, wantErr: true},
+		{name: "float overflow", input: `1e19`, wantErr: true},
+		{name: "float of 2^63", input: `9.223372036854775808e+18`, wantErr: true},
+		{name: "float underflow", input: `-1e19`, wantErr: true},
+		{name: "float beyond float64", input: `1e400`, wantErr: true},
+		{name: "non-numeric string", input: `"1TB"`, wantErr: true},
+		{name: "boolean", input: `true`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Int64
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsDecodeMixedRepresentations(t *testing.T) {
+	payload := `{
+		"storage_box_type": {"name": "BX10", "size": "1099511627776"},
+		"stats": {"size": 536870912000, "size_data": "429496729600", "size_snapshots": 1.073741824e+11}
+	}`
+
+	var box StorageBox
+	if err := json.Unmarshal([]byte(payload), &box); err != nil {
+		t.Fatalf("failed to decode storage box: %v", err)
+	}
+	if box.StorageBoxType.Size != 1099511627776 {
+		t.Errorf("unexpected quota %d", box.StorageBoxType.Size)
+	}
+	if box.Stats.Size != 536870912000 || box.Stats.SizeData != 429496729600 || box.Stats.SizeSnapshots != 107374182400 {
+		t.Errorf("unexpected stats %+v", box.Stats)
+	}
+
+	encoded, err := json.Marshal(box.Stats)
+	if err != nil {
+		t.Fatalf("failed to encode stats: %v", err)
+	}
+	if string(encoded) != `{"size":536870912000,"size_data":429496729600,"size_snapshots":107374182400}` {
+		t.Errorf("unexpected encoding %s", encoded)
+	}
+}
@@ -0,0 +1,42 @@
+package textfile
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Write gathers metrics from g and writes them in the text exposition format
+// to path, e.g. for pickup by the node_exporter textfile collector. The file
+// is written to a temporary file in the same directory and renamed, so
+// readers never see a partially written file.
+func Write(g prometheus.Gatherer, path string) error {
+	if err := prometheus.WriteToTextfile(path, g); err != nil {
+		return fmt.Errorf("failed to write metrics to %s: %w", path, err)
+	}
+	return nil
+}
+
+// Run writes the metrics to path immediately and then every interval until
+// ctx is cancelled. Write failures are logged and retried on the next tick.
+func Run(ctx context.Context, g prometheus.Gatherer, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := Write(g, path); err != nil {
+			slog.Error("Failed to write metrics file", "path", path, "error", err)
+		} else {
+			slog.Debug("Wrote metrics file", "path", path)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package textfile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+func newTestRegistry(t *testing.T) *prometheus.Registry {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "storagebox_disk_usage_bytes",
+		Help: "Total used diskspace in bytes",
+	})
+	gauge.Set(536870912000)
+	reg.MustRegister(gauge)
+	return reg
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "storagebox.prom")
+
+	if err := Write(newTestRegistry(t), path); err != nil {
+		t.Fatalf("Write() unexpected error = %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open metrics file: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	parser := expfmt.NewTextParser(model.LegacyValidation)
+	families, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		t.Fatalf("metrics file is not valid exposition format: %v", err)
+	}
+	mf, ok := families["storagebox_disk_usage_bytes"]
+	if !ok {
+		t.Fatal("expected storagebox_disk_usage_bytes in the metrics file")
+	}
+	if got := mf.GetMetric()[0].GetGauge().GetValue(); got != 536870912000 {
+		t.Errorf("expected 536870912000, got %v", got)
+	}
+
+	// Only the final file remains, no temporary files
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the metrics file in the directory, got %d entries", len(entries))
+	}
+}
+
+func TestWriteInvalidPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "storagebox.prom")
+	if err := Write(newTestRegistry(t), path); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storagebox.prom")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Run(ctx, newTestRegistry(t), path, time.Hour)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the metrics file to be written immediately")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
@@ -1,18 +1,25 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/crstian19/prometheus-storagebox-exporter/internal/collector"
 	"github.com/crstian19/prometheus-storagebox-exporter/internal/config"
 	"github.com/crstian19/prometheus-storagebox-exporter/internal/hetzner"
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/textfile"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -33,7 +40,9 @@ func main() {
 	}
 
 	// Initialize structured logger with JSON output
-	logLevel := parseLogLevel(cfg.LogLevel)
+	// The level is a LevelVar so config reloads can change it
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(parseLogLevel(cfg.LogLevel))
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: logLevel,
 	}))
@@ -47,28 +56,302 @@ func main() {
 		fmt.Printf("Build Date: %s\n", BuildDate)
 		os.Exit(0)
 	}
+	if cfg.PrintScrapeConfig {
+		if err := printScrapeConfig(os.Stdout, cfg); err != nil {
+			slog.Error("Failed to print scrape config", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
 	// Initialize Hetzner API client
-	hetznerClient := hetzner.NewClient(cfg.HetznerToken)
+	transport := hetzner.NewTransport(cfg.MaxConnsPerHost)
+	hetznerClient := hetzner.NewClientWithTransport(cfg.HetznerToken, transport)
+	hetznerClient.SetAuthScheme(cfg.AuthScheme)
+	hetznerClient.SetPaginationConcurrency(cfg.PaginationConcurrency)
+	hetznerClient.SetPartialPagination(cfg.PaginationOnError == "partial")
+	hetznerClient.SetMaxPages(cfg.MaxPages)
+	hetznerClient.SetSuccessStatusCodes(cfg.SuccessStatusCodes)
+	hetznerClient.SetRateLimit(cfg.APIRateLimit)
+	hetznerClient.SetMaxAttempts(cfg.APIMaxAttempts)
+	hetznerClient.SetRetryBaseDelay(cfg.APIRetryBaseDelay)
+	hetznerClient.SetMaxRetryAfter(cfg.MaxRetryAfter)
+	hetznerClient.SetForceHTTP1(cfg.ForceHTTP1)
+	hetznerClient.SetTransportTimeouts(cfg.DialTimeout, cfg.TLSHandshakeTimeout, cfg.ResponseHeaderTimeout)
+	if cfg.ConnectionWarmup && !cfg.PrintMetricsSchema {
+		warmupConnection(hetznerClient, cfg.APITimeout)
+	}
 
 	// Create and register the storage box collector with cache
 	buildInfo := collector.BuildInfo{Version: Version, Commit: GitCommit, BuildDate: BuildDate}
 	collector := collector.NewStorageBoxCollector(hetznerClient, cfg.CacheTTL, cfg.CacheMaxSize, cfg.CacheCleanupInterval, buildInfo)
-	prometheus.MustRegister(collector)
+	collector.SetSuccessWindow(cfg.APISuccessWindow)
+	collector.SetUsageCounter(cfg.UsageCounter)
+	collector.SetSizeUnit(cfg.SizeUnit, cfg.SizeRound)
+	collector.SetBooleanStyle(cfg.BooleanStyle == "stateset")
+	collector.SetExternalAccessAlias(cfg.ExternalAccessAlias)
+	collector.SetAccessProtocolMetric(cfg.AccessProtocolMetric)
+	collector.SetScrapeSummaryLog(cfg.ScrapeSummaryLog)
+	collector.SetCreatedLabel(cfg.InfoCreatedLabel)
+	collector.SetSkipInactive(cfg.SkipInactive)
+	collector.SetSnapshotMetrics(cfg.SnapshotMetrics)
+	collector.SetDetailConcurrency(cfg.DetailConcurrency)
+	collector.SetNameConvention(cfg.NameConvention)
+	collector.SetRequiredLabels(cfg.RequiredLabels)
+	collector.SetMaxLabelProjection(cfg.MaxLabelProjection)
+	if err := collector.SetExportedLabels(cfg.ExportLabels); err != nil {
+		slog.Error("Invalid --export-label", "error", err)
+		os.Exit(1)
+	}
+	collector.SetAPITimeout(cfg.APITimeout)
+	collector.SetPollInterval(cfg.PollInterval)
+	collector.SetPollTimestamps(cfg.PollTimestamps)
+	collector.SetMinScrapeInterval(cfg.MinScrapeInterval)
+	collector.SetUpFailureGrace(cfg.UpFailureGrace)
+	collector.SetTokenSource(cfg.TokenSource)
+	if err := collector.SetExcludedMetrics(cfg.ExcludeMetrics); err != nil {
+		slog.Error("Invalid --exclude-metric", "error", err)
+		os.Exit(1)
+	}
+	if err := collector.SetLabelRenames(cfg.LabelRenames); err != nil {
+		slog.Error("Invalid --label-rename", "error", err)
+		os.Exit(1)
+	}
+	if cfg.PrintMetricsSchema {
+		if err := printMetricsSchema(os.Stdout, collector); err != nil {
+			slog.Error("Failed to print metrics schema", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	// Connect Redis only once the collector is about to serve scrapes
+	if cfg.CacheStorageType == "redis" {
+		if err := collector.SetRedisCache(cfg.RedisURL); err != nil {
+			slog.Error("Invalid Redis cache configuration", "error", err)
+			os.Exit(1)
+		}
+	}
+	// Fail fast on duplicate or invalid metric names before serving anything
+	if err := validateCollector(collector); err != nil {
+		slog.Error("Invalid metric configuration", "error", err)
+		os.Exit(1)
+	}
+	// Scrapes register the collector per request with the request's options;
+	// the file writer gathers it through a registry of its own
+	fileMetrics, err := fileGatherer(collector)
+	if err != nil {
+		slog.Error("Failed to register collector", "error", err)
+		os.Exit(1)
+	}
+
+	publicHandler, adminHandler := newHandlers(cfg, collector)
 
-	// Set up HTTP server
+	// Admin endpoints get their own listener when an admin address is set
+	servers := []*http.Server{newHTTPServer(cfg.ListenAddress, publicHandler)}
+	if adminHandler != nil {
+		servers = append(servers, newHTTPServer(cfg.AdminListenAddress, adminHandler))
+	}
+
+	// Set up graceful shutdown
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+
+	slog.Info("Starting prometheus-storagebox-exporter",
+		"version", Version,
+		"git_commit", GitCommit,
+		"build_date", BuildDate,
+		"listen_address", cfg.ListenAddress,
+		"admin_listen_address", cfg.AdminListenAddress,
+		"tls", cfg.TLSEnabled(),
+		"metrics_path", cfg.MetricsPath,
+		"log_level", cfg.LogLevel,
+	)
+	if cfg.TLSEnabled() {
+		tlsConfig, err := cfg.TLSConfig()
+		if err != nil {
+			slog.Error("Invalid TLS configuration", "error", err)
+			os.Exit(1)
+		}
+		for _, srv := range servers {
+			srv.TLSConfig = tlsConfig
+		}
+	}
+
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			var err error
+			if cfg.TLSEnabled() {
+				err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+			} else {
+				err = srv.ListenAndServe()
+			}
+			if err != nil && err != http.ErrServerClosed {
+				slog.Error("HTTP server failed", "address", srv.Addr, "error", err)
+				os.Exit(1)
+			}
+		}(srv)
+	}
+
+	// Reload the token file and config file on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	reloader := newReloader(cfg, hetznerClient, collector, logLevel)
+	go func() {
+		for range hup {
+			reloader.reload("SIGHUP")
+		}
+	}()
+
+	// Background tasks run until shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Release memory of expired cache entries between scrapes. It also runs
+	// with the cache disabled, as a reload may enable it.
+	go collector.RunCacheCleanup(bgCtx)
+
+	// Poll the API independently of scrapes
+	if cfg.PollInterval > 0 {
+		slog.Info("Polling Hetzner API in the background", "interval", cfg.PollInterval)
+		go collector.RunPoller(bgCtx)
+	}
+
+	// Write metrics to a file for air-gapped collection
+	if cfg.OutputFile != "" {
+		slog.Info("Writing metrics to file", "path", cfg.OutputFile, "interval", cfg.OutputInterval)
+		go textfile.Run(bgCtx, fileMetrics, cfg.OutputFile, cfg.OutputInterval)
+	}
+
+	<-stop
+
+	slog.Info("Shutting down gracefully")
+	stopBackground()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			slog.Error("Error during shutdown", "address", srv.Addr, "error", err)
+		}
+	}
+
+	slog.Info("Exporter stopped")
+}
+
+// warmupConnection connects to the Hetzner API ahead of the first scrape.
+// Failures are only logged; the first scrape then connects as usual.
+func warmupConnection(client *hetzner.Client, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := client.Warmup(ctx); err != nil {
+		slog.Warn("Connection warmup failed", "error", err)
+		return
+	}
+	slog.Info("Connection warmup completed", "duration", time.Since(start))
+}
+
+// printMetricsSchema writes the metric names and label keys of c as indented
+// JSON, for CI jobs diffing it to catch breaking metric changes
+func printMetricsSchema(w io.Writer, c *collector.StorageBoxCollector) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c.Schema())
+}
+
+// printScrapeConfig writes a Prometheus scrape_configs snippet targeting the
+// exporter at the configured listen address and metrics path. A listen address
+// without a specific host is targeted as localhost.
+func printScrapeConfig(w io.Writer, cfg *config.Config) error {
+	host, port, err := net.SplitHostPort(cfg.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", cfg.ListenAddress, err)
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	scheme := "http"
+	if cfg.TLSEnabled() {
+		scheme = "https"
+	}
+
+	_, err = fmt.Fprintf(w, `scrape_configs:
+  - job_name: 'hetzner-storagebox'
+    scheme: %s
+    metrics_path: '%s'
+    static_configs:
+      - targets: ['%s']
+    scrape_interval: 60s
+    scrape_timeout: 30s
+`, scheme, cfg.MetricsPath, net.JoinHostPort(host, port))
+	return err
+}
+
+// validateCollector registers c against a throwaway registry, surfacing
+// duplicate or invalid metric descriptors at startup rather than at the first
+// scrape. No metrics are collected, so the Hetzner API is not called.
+func validateCollector(c prometheus.Collector) error {
+	if err := prometheus.NewPedanticRegistry().Register(c); err != nil {
+		return fmt.Errorf("collector registration failed: %w", err)
+	}
+	return nil
+}
+
+// fileGatherer returns the gatherer of the metrics file. It holds only the
+// exporter's own metrics, as node_exporter, which reads the file, exports the
+// Go runtime and process metrics itself, and leaves out poll timestamps, which
+// its textfile collector rejects.
+func fileGatherer(c *collector.StorageBoxCollector) (prometheus.Gatherer, error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(c.Scrape(collector.ScrapeOptions{NoPollTimestamps: true})); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// newHandlers builds the public handler serving metrics, health and the landing
+// page, and the admin handler serving the admin endpoints when they are served
+// on a separate admin listener. The admin handler is nil otherwise; admin
+// endpoints enabled without an admin listener are served on the public handler.
+func newHandlers(cfg *config.Config, c *collector.StorageBoxCollector) (public, admin http.Handler) {
 	mux := http.NewServeMux()
 
 	// Metrics endpoint
-	mux.Handle(cfg.MetricsPath, promhttp.Handler())
+	metricsHandler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, scrapeHandler(c, cfg.AllowRefresh))
+	// Scrapes of all metrics endpoints and CSV exports share the same slots
+	limit := func(next http.Handler) http.Handler { return next }
+	if cfg.MaxConcurrentScrapes > 0 {
+		limit = newScrapeLimiter(cfg.MaxConcurrentScrapes, cfg.ScrapeQueueTimeout)
+	}
+	metricsHandler = scrapeLogHandler(limit(metricsHandler))
+	mux.Handle(cfg.MetricsPath, metricsHandler)
+	if cfg.MetricsPath != "/" {
+		// The path is canonicalized without a trailing slash; serve the
+		// slashed form too so scrape configs match either way
+		mux.Handle(cfg.MetricsPath+"/{$}", metricsHandler)
+	}
+
+	// Incremental endpoint emitting only boxes created after ?since=
+	if cfg.MetricsSince {
+		mux.Handle("/metrics/since", limit(sinceHandler(c)))
+	}
+
+	// Spreadsheet snapshot of the storage boxes
+	if cfg.ExportCSV {
+		mux.Handle("/export.csv", limit(exportCSVHandler(c)))
+	}
 
 	// Health check endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("OK")); err != nil {
-			// Log the error but don't fail the health check
-			slog.Warn("Failed to write health check response", "error", err)
+	mux.Handle("/health", healthHandler(c))
+
+	// Readiness endpoint, weighing API errors by type
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		if ready, reason := c.Ready(); !ready {
+			http.Error(w, "Not ready: "+reason, http.StatusServiceUnavailable)
+			return
 		}
+		_, _ = w.Write([]byte("Ready"))
 	})
 
 	// Landing page
@@ -77,6 +360,14 @@ func main() {
 			http.NotFound(w, r)
 			return
 		}
+		if cfg.LandingRedirect {
+			http.Redirect(w, r, cfg.MetricsPath, http.StatusFound)
+			return
+		}
+		sinceLink := ""
+		if cfg.MetricsSince {
+			sinceLink = "\t<p><a href=\"/metrics/since?since=0\">Metrics since</a> (boxes created after <code>?since=</code>)</p>\n"
+		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		_, _ = fmt.Fprintf(w, `<!DOCTYPE html>
 <html>
@@ -99,63 +390,207 @@ func main() {
 	</div>
 	<p><a href="%s">Metrics</a></p>
 	<p><a href="/health">Health Check</a></p>
-	<h2>About</h2>
+	<p><a href="/ready">Readiness Check</a></p>
+%s	<h2>About</h2>
 	<p>This exporter collects metrics from Hetzner Storage Boxes and exposes them in Prometheus format.</p>
 	<h3>Metrics Exposed:</h3>
 	<ul>
 		<li>storagebox_disk_usage_bytes - Total used diskspace</li>
 		<li>storagebox_disk_usage_data_bytes - Diskspace used by files</li>
 		<li>storagebox_disk_usage_snapshots_bytes - Diskspace used by snapshots</li>
+		<li>storagebox_disk_usage_ratio - Used diskspace relative to the quota</li>
 		<li>storagebox_info - Storage box information</li>
 		<li>storagebox_status - Current status</li>
-		<li>storagebox_access_*_enabled - Access settings (SSH, Samba, WebDAV)</li>
+		<li>storagebox_access_*_enabled - Access settings (SSH, Samba, WebDAV, ZFS)</li>
+		<li>storagebox_reachable_externally - External reachability (also storagebox_access_external_enabled with --access-external-alias)</li>
 		<li>storagebox_snapshot_plan_enabled - Snapshot plan status</li>
 		<li>storagebox_protection_delete - Delete protection status</li>
 		<li>storagebox_created_timestamp - Creation timestamp</li>
 	</ul>
 </body>
 </html>
-`, Version, GitCommit, BuildDate, cfg.MetricsPath)
+`, Version, GitCommit, BuildDate, cfg.MetricsPath, sinceLink)
 	})
 
-	server := &http.Server{
-		Addr:         cfg.ListenAddress,
-		Handler:      mux,
+	switch {
+	case cfg.AdminListenAddress != "":
+		adminMux := http.NewServeMux()
+		registerAdminHandlers(adminMux, c)
+		admin = adminMux
+	case cfg.EnableAdminAPI:
+		registerAdminHandlers(mux, c)
+	}
+
+	return mux, admin
+}
+
+// newHTTPServer creates an HTTP server with the exporter's default timeouts
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+}
 
-	// Set up graceful shutdown
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+// registerAdminHandlers registers the admin endpoints that pause and resume
+// Hetzner API calls, e.g. during planned maintenance
+func registerAdminHandlers(mux *http.ServeMux, c *collector.StorageBoxCollector) {
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		c.SetPaused(true)
+		slog.Info("Hetzner API calls paused, serving last-known data", "remote_addr", r.RemoteAddr)
+		_, _ = w.Write([]byte("Paused"))
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		c.SetPaused(false)
+		slog.Info("Hetzner API calls resumed", "remote_addr", r.RemoteAddr)
+		_, _ = w.Write([]byte("Resumed"))
+	})
+}
 
-	go func() {
-		slog.Info("Starting prometheus-storagebox-exporter",
-			"version", Version,
-			"git_commit", GitCommit,
-			"build_date", BuildDate,
-			"listen_address", cfg.ListenAddress,
-			"metrics_path", cfg.MetricsPath,
-			"log_level", cfg.LogLevel,
-		)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("HTTP server failed", "error", err)
-			os.Exit(1)
+// scrapeHandler serves the metrics of the default registry together with those
+// of c, collected with the options of the request. With allowRefresh,
+// ?refresh=1 fetches fresh data for that scrape, bypassing the cache.
+func scrapeHandler(c *collector.StorageBoxCollector, allowRefresh bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts := collector.ScrapeOptions{Context: r.Context()}
+		if allowRefresh && r.URL.Query().Get("refresh") == "1" {
+			slog.Debug("Bypassing cache on request", "remote_addr", r.RemoteAddr)
+			opts.Refresh = true
 		}
-	}()
 
-	<-stop
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(c.Scrape(opts))
+		promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, reg}, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
 
-	slog.Info("Shutting down gracefully")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// scrapeLogHandler logs who requested each scrape at debug level, to identify
+// noisy scrapers sharing the exporter. The remote address is deliberately not
+// a metric label, as it would be unbounded.
+func scrapeLogHandler(next http.Handler) http.Handler {
+	var scrapeID atomic.Uint64
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := scrapeID.Add(1)
+		start := time.Now()
+		slog.Debug("Scrape started", "scrape_id", id, "remote_addr", r.RemoteAddr, "user_agent", r.UserAgent())
+		next.ServeHTTP(w, r)
+		slog.Debug("Scrape finished", "scrape_id", id, "remote_addr", r.RemoteAddr, "duration", time.Since(start))
+	})
+}
 
-	if err := server.Shutdown(ctx); err != nil {
-		slog.Error("Error during shutdown", "error", err)
+// newScrapeLimiter returns a middleware serving at most limit scrapes at once
+// across all handlers it wraps. Without a queue timeout excess scrapes are
+// rejected with 429 at once; otherwise they wait up to queueTimeout for a free
+// slot before getting 503.
+func newScrapeLimiter(limit int, queueTimeout time.Duration) func(http.Handler) http.Handler {
+	slots := make(chan struct{}, limit)
+	return func(next http.Handler) http.Handler {
+		return scrapeLimitHandler(next, slots, limit, queueTimeout)
 	}
+}
 
-	slog.Info("Exporter stopped")
+// scrapeLimitHandler serves next while holding one of slots, see
+// newScrapeLimiter
+func scrapeLimitHandler(next http.Handler, slots chan struct{}, limit int, queueTimeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			if queueTimeout <= 0 {
+				slog.Warn("Rejecting scrape, too many concurrent scrapes", "limit", limit, "remote_addr", r.RemoteAddr)
+				http.Error(w, "Too many concurrent scrapes", http.StatusTooManyRequests)
+				return
+			}
+
+			timer := time.NewTimer(queueTimeout)
+			defer timer.Stop()
+			select {
+			case slots <- struct{}{}:
+			case <-timer.C:
+				slog.Warn("Rejecting scrape, no slot freed up in time", "limit", limit, "queue_timeout", queueTimeout, "remote_addr", r.RemoteAddr)
+				http.Error(w, "Timed out waiting for a scrape slot", http.StatusServiceUnavailable)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+		defer func() { <-slots }()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sinceHandler serves per-box metrics only for storage boxes created after the
+// time given in the since query parameter (RFC 3339 or Unix seconds)
+func sinceHandler(c *collector.StorageBoxCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since, err := parseSince(r.URL.Query().Get("since"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(c.Since(r.Context(), since))
+		promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// exportCSVHandler serves the storage boxes as CSV. The CSV is rendered
+// before anything is written, so a failed API call yields a 503 rather than a
+// truncated file.
+func exportCSVHandler(c *collector.StorageBoxCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := c.WriteCSV(r.Context(), &buf); err != nil {
+			slog.Warn("Failed to export storage boxes as CSV", "error", err)
+			http.Error(w, "Failed to fetch storage boxes", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="storageboxes.csv"`)
+		_, _ = buf.WriteTo(w)
+	})
+}
+
+// healthHandler answers health checks with a plain OK, or with the exporter's
+// version, cache backend and last scrape outcome as JSON given ?verbose=1.
+// The health check succeeds either way; the last scrape outcome is only
+// informational.
+func healthHandler(c *collector.StorageBoxCollector) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if verbose, _ := strconv.ParseBool(r.URL.Query().Get("verbose")); verbose {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(c.Health()); err != nil {
+				slog.Warn("Failed to write health check response", "error", err)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte("OK")); err != nil {
+			// Log the error but don't fail the health check
+			slog.Warn("Failed to write health check response", "error", err)
+		}
+	})
+}
+
+// parseSince parses a since query parameter given as RFC 3339 timestamp or
+// Unix seconds
+func parseSince(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("missing since query parameter")
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since query parameter %q: expected RFC 3339 timestamp or Unix seconds", value)
+	}
+	return since, nil
 }
 
 // parseLogLevel converts a string log level to slog.Level
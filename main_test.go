@@ -0,0 +1,883 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/collector"
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/config"
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/hetzner"
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/textfile"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+// newTestCollector returns a collector backed by a mock Hetzner API serving
+// a single storage box, and a counter of API calls made.
+func newTestCollector(t *testing.T) (*collector.StorageBoxCollector, *atomic.Int32) {
+	t.Helper()
+	return newCachedTestCollector(t, 0)
+}
+
+// newCachedTestCollector is like newTestCollector with the given cache TTL.
+func newCachedTestCollector(t *testing.T, cacheTTL time.Duration) (*collector.StorageBoxCollector, *atomic.Int32) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"storage_boxes": []map[string]interface{}{
+				{"id": 1, "name": "box", "status": "active"},
+			},
+		}); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	}))
+	t.Cleanup(api.Close)
+
+	client := hetzner.NewClient("test-token")
+	client.SetBaseURL(api.URL)
+	return collector.NewStorageBoxCollector(client, cacheTTL, 0, 0, collector.BuildInfo{}), calls
+}
+
+func TestAdminPauseResume(t *testing.T) {
+	c, calls := newTestCollector(t)
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	registerAdminHandlers(mux, c)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	post := func(path string) {
+		t.Helper()
+		resp, err := http.Post(server.URL+path, "text/plain", nil)
+		if err != nil {
+			t.Fatalf("POST %s failed: %v", path, err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s returned status %d", path, resp.StatusCode)
+		}
+	}
+
+	gather := func() {
+		t.Helper()
+		if _, err := reg.Gather(); err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+	}
+
+	gather()
+	post("/pause")
+	if !c.Paused() {
+		t.Fatal("expected collector to be paused")
+	}
+	gather()
+	gather()
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected no API calls while paused, got %d total", got)
+	}
+
+	post("/resume")
+	if c.Paused() {
+		t.Fatal("expected collector to be resumed")
+	}
+	gather()
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected API calls after resume, got %d total", got)
+	}
+
+	// Admin endpoints only accept POST.
+	resp, err := http.Get(server.URL + "/pause")
+	if err != nil {
+		t.Fatalf("GET /pause failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for GET /pause, got %d", resp.StatusCode)
+	}
+}
+
+func TestSinceHandler(t *testing.T) {
+	c, _ := newTestCollector(t)
+	server := httptest.NewServer(sinceHandler(c))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{name: "unix seconds", query: "?since=0", wantStatus: http.StatusOK},
+		{name: "RFC 3339", query: "?since=2024-01-01T00:00:00Z", wantStatus: http.StatusOK},
+		{name: "missing", query: "", wantStatus: http.StatusBadRequest},
+		{name: "invalid", query: "?since=yesterday", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.query)
+			if err != nil {
+				t.Fatalf("GET failed: %v", err)
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	got, err := parseSince("1700000000")
+	if err != nil {
+		t.Fatalf("parseSince() unexpected error = %v", err)
+	}
+	if got.Unix() != 1700000000 {
+		t.Errorf("expected 1700000000, got %d", got.Unix())
+	}
+
+	got, err = parseSince("2024-03-01T12:00:00Z")
+	if err != nil {
+		t.Fatalf("parseSince() unexpected error = %v", err)
+	}
+	if !got.Equal(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected time %v", got)
+	}
+}
+
+// statusCode performs a request and returns the response status code
+func statusCode(t *testing.T, method, url string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAdminListenAddress(t *testing.T) {
+	c, _ := newTestCollector(t)
+	cfg := &config.Config{MetricsPath: "/metrics", AdminListenAddress: "127.0.0.1:0"}
+
+	public, admin := newHandlers(cfg, c)
+	if admin == nil {
+		t.Fatal("expected an admin handler when an admin listen address is set")
+	}
+	publicServer := httptest.NewServer(public)
+	defer publicServer.Close()
+	adminServer := httptest.NewServer(admin)
+	defer adminServer.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+	}{
+		{name: "metrics on public listener", method: http.MethodGet, url: publicServer.URL + "/metrics", wantStatus: http.StatusOK},
+		{name: "health on public listener", method: http.MethodGet, url: publicServer.URL + "/health", wantStatus: http.StatusOK},
+		{name: "pause not on public listener", method: http.MethodPost, url: publicServer.URL + "/pause", wantStatus: http.StatusNotFound},
+		{name: "pause on admin listener", method: http.MethodPost, url: adminServer.URL + "/pause", wantStatus: http.StatusOK},
+		{name: "resume on admin listener", method: http.MethodPost, url: adminServer.URL + "/resume", wantStatus: http.StatusOK},
+		{name: "metrics not on admin listener", method: http.MethodGet, url: adminServer.URL + "/metrics", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statusCode(t, tt.method, tt.url); got != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, got)
+			}
+		})
+	}
+}
+
+func TestAdminEndpointsOnPublicListener(t *testing.T) {
+	c, _ := newTestCollector(t)
+
+	public, admin := newHandlers(&config.Config{MetricsPath: "/metrics", EnableAdminAPI: true}, c)
+	if admin != nil {
+		t.Error("expected no admin handler without an admin listen address")
+	}
+	server := httptest.NewServer(public)
+	defer server.Close()
+	if got := statusCode(t, http.MethodPost, server.URL+"/pause"); got != http.StatusOK {
+		t.Errorf("expected admin endpoints on the public listener, got status %d", got)
+	}
+
+	public, _ = newHandlers(&config.Config{MetricsPath: "/metrics"}, c)
+	disabled := httptest.NewServer(public)
+	defer disabled.Close()
+	if got := statusCode(t, http.MethodPost, disabled.URL+"/pause"); got != http.StatusNotFound {
+		t.Errorf("expected admin endpoints to be disabled by default, got status %d", got)
+	}
+}
+
+func TestLandingRedirect(t *testing.T) {
+	c, _ := newTestCollector(t)
+	noRedirect := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	tests := []struct {
+		name         string
+		redirect     bool
+		wantStatus   int
+		wantLocation string
+	}{
+		{name: "landing page", redirect: false, wantStatus: http.StatusOK},
+		{name: "redirect", redirect: true, wantStatus: http.StatusFound, wantLocation: "/custom-metrics"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			public, _ := newHandlers(&config.Config{MetricsPath: "/custom-metrics", LandingRedirect: tt.redirect}, c)
+			server := httptest.NewServer(public)
+			defer server.Close()
+
+			resp, err := noRedirect.Get(server.URL + "/")
+			if err != nil {
+				t.Fatalf("GET / failed: %v", err)
+			}
+			_ = resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Location"); got != tt.wantLocation {
+				t.Errorf("expected Location %q, got %q", tt.wantLocation, got)
+			}
+		})
+	}
+}
+
+func TestMetricsPathTrailingSlash(t *testing.T) {
+	c, _ := newTestCollector(t)
+	public, _ := newHandlers(&config.Config{MetricsPath: "/metrics"}, c)
+	server := httptest.NewServer(public)
+	defer server.Close()
+
+	for _, path := range []string{"/metrics", "/metrics/"} {
+		if got := statusCode(t, http.MethodGet, server.URL+path); got != http.StatusOK {
+			t.Errorf("expected %s to serve metrics, got status %d", path, got)
+		}
+	}
+	if got := statusCode(t, http.MethodGet, server.URL+"/metrics/unknown"); got != http.StatusNotFound {
+		t.Errorf("expected only the exact slashed path to be served, got status %d", got)
+	}
+}
+
+func TestHealthVerbose(t *testing.T) {
+	c, _ := newTestCollector(t)
+	reg := prometheus.NewRegistry()
+	if err := reg.Register(c); err != nil {
+		t.Fatalf("failed to register collector: %v", err)
+	}
+	public, _ := newHandlers(&config.Config{MetricsPath: "/metrics", HetznerToken: "secret-token"}, c)
+	server := httptest.NewServer(public)
+	defer server.Close()
+
+	health := func(query string) (string, *http.Response) {
+		resp, err := http.Get(server.URL + "/health" + query)
+		if err != nil {
+			t.Fatalf("GET /health%s failed: %v", query, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatalf("failed to read body: %v", err)
+		}
+		return string(body), resp
+	}
+
+	if body, _ := health(""); body != "OK" {
+		t.Errorf("expected plain OK by default, got %q", body)
+	}
+
+	body, resp := health("?verbose=1")
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON, got Content-Type %q", ct)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", body, err)
+	}
+	if got["go_version"] != runtime.Version() || got["cache_backend"] != "memory" || got["last_scrape"] != "none" {
+		t.Errorf("unexpected health before the first scrape: %v", got)
+	}
+	if _, ok := got["version"]; !ok {
+		t.Errorf("expected a version field, got %v", got)
+	}
+	if strings.Contains(body, "secret-token") {
+		t.Errorf("expected no token in the health response, got %q", body)
+	}
+
+	if _, err := reg.Gather(); err != nil {
+		t.Fatalf("Gather() unexpected error: %v", err)
+	}
+	body, _ = health("?verbose=1")
+	got = nil
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", body, err)
+	}
+	if got["last_scrape"] != "success" || got["last_scrape_success"] == nil {
+		t.Errorf("expected a successful last scrape after gathering, got %v", got)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	c, _ := newTestCollector(t)
+
+	disabled, _ := newHandlers(&config.Config{MetricsPath: "/metrics"}, c)
+	server := httptest.NewServer(disabled)
+	defer server.Close()
+	if got := statusCode(t, http.MethodGet, server.URL+"/export.csv"); got != http.StatusNotFound {
+		t.Errorf("expected /export.csv to be disabled by default, got status %d", got)
+	}
+
+	enabled, _ := newHandlers(&config.Config{MetricsPath: "/metrics", ExportCSV: true}, c)
+	server = httptest.NewServer(enabled)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/export.csv")
+	if err != nil {
+		t.Fatalf("GET /export.csv failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/csv") {
+		t.Errorf("expected a CSV content type, got %q", got)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if strings.Contains(string(body), "test-token") {
+		t.Error("expected the API token to be absent from the export")
+	}
+	rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to parse CSV: %v", err)
+	}
+	wantHeader := "id,name,type,quota_bytes,usage_bytes,location,status,delete_protection,snapshot_plan_enabled"
+	if len(rows) == 0 || strings.Join(rows[0], ",") != wantHeader {
+		t.Fatalf("expected header %q, got %v", wantHeader, rows)
+	}
+	// The test API returns a single box
+	if len(rows) != 2 {
+		t.Fatalf("expected one row per box, got %d rows", len(rows)-1)
+	}
+	if rows[1][0] != "1" || rows[1][1] != "box" || rows[1][6] != "active" {
+		t.Errorf("unexpected row %v", rows[1])
+	}
+}
+
+// misconfiguredCollector describes the same metric name twice with
+// inconsistent label names, as conflicting renames would
+type misconfiguredCollector struct{}
+
+func (misconfiguredCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- prometheus.NewDesc("storagebox_disk_usage_bytes", "Total used diskspace in bytes", []string{"id"}, nil)
+	ch <- prometheus.NewDesc("storagebox_disk_usage_bytes", "Total used diskspace in bytes", []string{"name"}, nil)
+}
+
+func (misconfiguredCollector) Collect(chan<- prometheus.Metric) {}
+
+func TestValidateCollector(t *testing.T) {
+	c, calls := newTestCollector(t)
+	if err := validateCollector(c); err != nil {
+		t.Errorf("validateCollector() unexpected error = %v", err)
+	}
+	if got := calls.Load(); got != 0 {
+		t.Errorf("expected validation not to call the API, got %d calls", got)
+	}
+
+	err := validateCollector(misconfiguredCollector{})
+	if err == nil {
+		t.Fatal("expected an error for a misconfigured collector")
+	}
+	if !strings.Contains(err.Error(), "storagebox_disk_usage_bytes") {
+		t.Errorf("expected the error to name the conflicting metric, got %v", err)
+	}
+}
+
+func TestFileGatherer(t *testing.T) {
+	c, calls := newTestCollector(t)
+	c.SetPollInterval(time.Hour)
+	c.SetPollTimestamps(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.RunPoller(ctx)
+
+	// Wait until scrapes carry the time of the first poll
+	scrape := prometheus.NewRegistry()
+	scrape.MustRegister(c.Scrape(collector.ScrapeOptions{}))
+	deadline := time.Now().Add(5 * time.Second)
+	for stamped := false; !stamped; {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected poll timestamps on scrapes after the first poll, got %d API calls", calls.Load())
+		}
+		families, err := scrape.Gather()
+		if err != nil {
+			t.Fatalf("Gather() unexpected error = %v", err)
+		}
+		for _, mf := range families {
+			for _, m := range mf.GetMetric() {
+				stamped = stamped || m.TimestampMs != nil
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	gatherer, err := fileGatherer(c)
+	if err != nil {
+		t.Fatalf("fileGatherer() unexpected error = %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "storagebox.prom")
+	if err := textfile.Write(gatherer, path); err != nil {
+		t.Fatalf("Write() unexpected error = %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open metrics file: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	parser := expfmt.NewTextParser(model.LegacyValidation)
+	families, err := parser.TextToMetricFamilies(f)
+	if err != nil {
+		t.Fatalf("metrics file is not valid exposition format: %v", err)
+	}
+
+	if _, ok := families["storagebox_disk_quota_bytes"]; !ok {
+		t.Error("expected the box metrics in the metrics file")
+	}
+	for name, mf := range families {
+		if strings.HasPrefix(name, "go_") || strings.HasPrefix(name, "process_") || strings.HasPrefix(name, "promhttp_") {
+			t.Errorf("expected no runtime or handler metrics in the metrics file, got %s", name)
+		}
+		for _, m := range mf.GetMetric() {
+			if m.TimestampMs != nil {
+				t.Errorf("expected no timestamps in the metrics file, got one on %s", name)
+			}
+		}
+	}
+}
+
+func TestReadyEndpoint(t *testing.T) {
+	c, _ := newTestCollector(t)
+	public, _ := newHandlers(&config.Config{MetricsPath: "/metrics"}, c)
+	server := httptest.NewServer(public)
+	defer server.Close()
+
+	if got := statusCode(t, http.MethodGet, server.URL+"/ready"); got != http.StatusOK {
+		t.Errorf("expected /ready to return 200, got %d", got)
+	}
+}
+
+func TestScrapeHandlerRefresh(t *testing.T) {
+	c, calls := newCachedTestCollector(t, time.Hour)
+	server := httptest.NewServer(scrapeHandler(c, true))
+	defer server.Close()
+
+	for _, query := range []string{"", "", "?refresh=0"} {
+		if got := statusCode(t, http.MethodGet, server.URL+query); got != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", got)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("expected scrapes within the cache window to share one API call, got %d", got)
+	}
+
+	if got := statusCode(t, http.MethodGet, server.URL+"?refresh=1"); got != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", got)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected refresh=1 to make a fresh API call, got %d calls", got)
+	}
+
+	// The refreshed data repopulates the cache
+	if got := statusCode(t, http.MethodGet, server.URL); got != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", got)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected the next scrape to be served from the cache, got %d calls", got)
+	}
+
+	// Without --allow-refresh the parameter is ignored
+	disallowed := httptest.NewServer(scrapeHandler(c, false))
+	defer disallowed.Close()
+	if got := statusCode(t, http.MethodGet, disallowed.URL+"?refresh=1"); got != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", got)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected refresh=1 to be ignored unless allowed, got %d calls", got)
+	}
+}
+
+func TestScrapeHandlerRefreshMinScrapeInterval(t *testing.T) {
+	c, calls := newTestCollector(t)
+	c.SetMinScrapeInterval(time.Hour)
+	server := httptest.NewServer(scrapeHandler(c, true))
+	defer server.Close()
+
+	for _, query := range []string{"", "", "?refresh=1"} {
+		if got := statusCode(t, http.MethodGet, server.URL+query); got != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", got)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected refresh=1 to bypass the minimum scrape interval, got %d calls", got)
+	}
+}
+
+func TestScrapeHandlerCancelled(t *testing.T) {
+	var calls atomic.Int32
+	apiCalled := make(chan struct{})
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first call hangs until the scrape that made it is abandoned
+		if calls.Add(1) == 1 {
+			close(apiCalled)
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"storage_boxes": []map[string]interface{}{
+				{"id": 1, "name": "box", "status": "active"},
+			},
+		}); err != nil {
+			t.Errorf("Failed to encode mock response: %v", err)
+		}
+	}))
+	defer api.Close()
+	client := hetzner.NewClient("test-token")
+	client.SetBaseURL(api.URL)
+	c := collector.NewStorageBoxCollector(client, 0, 0, 0, collector.BuildInfo{})
+
+	scraped := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scrapeHandler(c, false).ServeHTTP(w, r)
+		scraped <- struct{}{}
+	}))
+	defer server.Close()
+
+	// Prometheus gives up on the scrape while the API call is in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	go func() {
+		<-apiCalled
+		cancel()
+	}()
+	if resp, err := http.DefaultClient.Do(req); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected the cancelled scrape to fail")
+	}
+	select {
+	case <-scraped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the cancelled scrape to return")
+	}
+
+	if got := c.Health().LastScrape; got != "none" {
+		t.Errorf("expected the cancelled scrape not to count as failed, got last scrape %q", got)
+	}
+	if ready, reason := c.Ready(); !ready {
+		t.Errorf("expected the exporter to stay ready, got %q", reason)
+	}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("failed to scrape: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	for _, want := range []string{
+		"storagebox_exporter_scrape_cancelled_total 1",
+		"storagebox_exporter_scrape_errors_total 0",
+		"storagebox_exporter_network_errors_total 0",
+		"storagebox_exporter_api_success_ratio 1",
+	} {
+		if !strings.Contains(string(body), want+"\n") {
+			t.Errorf("expected %q in the metrics", want)
+		}
+	}
+}
+
+func TestScrapeLimitHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		queueTimeout time.Duration
+		releaseAfter time.Duration
+		want         int
+	}{
+		{name: "rejected without queueing", queueTimeout: 0, want: http.StatusTooManyRequests},
+		{name: "queued then served", queueTimeout: 5 * time.Second, releaseAfter: 50 * time.Millisecond, want: http.StatusOK},
+		{name: "queue timeout", queueTimeout: 50 * time.Millisecond, want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entered := make(chan struct{}, 2)
+			release := make(chan struct{})
+			slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				entered <- struct{}{}
+				<-release
+			})
+			server := httptest.NewServer(newScrapeLimiter(1, tt.queueTimeout)(slow))
+			defer server.Close()
+
+			// Occupy the only slot
+			first := make(chan int, 1)
+			go func() { first <- statusCode(t, http.MethodGet, server.URL) }()
+			<-entered
+
+			if tt.releaseAfter > 0 {
+				time.AfterFunc(tt.releaseAfter, func() { close(release) })
+			} else {
+				defer close(release)
+			}
+
+			if got := statusCode(t, http.MethodGet, server.URL); got != tt.want {
+				t.Errorf("expected status %d for the excess scrape, got %d", tt.want, got)
+			}
+			if tt.releaseAfter > 0 {
+				if got := <-first; got != http.StatusOK {
+					t.Errorf("expected status 200 for the first scrape, got %d", got)
+				}
+			}
+		})
+	}
+}
+
+func TestWarmupConnection(t *testing.T) {
+	var methods []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer api.Close()
+
+	client := hetzner.NewClient("test-token")
+	client.SetBaseURL(api.URL)
+	logs := captureLogs(t)
+	warmupConnection(client, time.Second)
+
+	if len(methods) != 1 || methods[0] != http.MethodHead {
+		t.Errorf("expected a single HEAD warmup request, got %v", methods)
+	}
+	if !strings.Contains(logs.String(), "Connection warmup completed") {
+		t.Errorf("expected warmup success log, got %q", logs.String())
+	}
+
+	// Failures are logged, not fatal
+	api.Close()
+	logs.Reset()
+	warmupConnection(client, time.Second)
+	if !strings.Contains(logs.String(), "Connection warmup failed") {
+		t.Errorf("expected warmup failure log, got %q", logs.String())
+	}
+}
+
+func TestScrapeLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	server := httptest.NewServer(scrapeLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+	defer server.Close()
+
+	for i := 0; i < 2; i++ {
+		statusCode(t, http.MethodGet, server.URL)
+	}
+
+	var started []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if entry["msg"] == "Scrape started" {
+			started = append(started, entry)
+		}
+	}
+
+	if len(started) != 2 {
+		t.Fatalf("expected 2 scrape start entries, got %d: %s", len(started), buf.String())
+	}
+	for i, entry := range started {
+		if entry["level"] != "DEBUG" {
+			t.Errorf("expected debug level, got %v", entry["level"])
+		}
+		if addr, _ := entry["remote_addr"].(string); !strings.HasPrefix(addr, "127.0.0.1:") {
+			t.Errorf("expected the client's remote_addr, got %v", entry["remote_addr"])
+		}
+		if entry["scrape_id"] != float64(i+1) {
+			t.Errorf("expected scrape_id %d, got %v", i+1, entry["scrape_id"])
+		}
+	}
+}
+
+func TestPrintMetricsSchema(t *testing.T) {
+	c, calls := newTestCollector(t)
+
+	var buf bytes.Buffer
+	if err := printMetricsSchema(&buf, c); err != nil {
+		t.Fatalf("printMetricsSchema() unexpected error: %v", err)
+	}
+
+	var schema []collector.MetricSchema
+	if err := json.Unmarshal(buf.Bytes(), &schema); err != nil {
+		t.Fatalf("invalid schema JSON: %v", err)
+	}
+	found := false
+	for _, metric := range schema {
+		if metric.Name == "storagebox_status" {
+			found = true
+			if strings.Join(metric.Labels, ",") != "id,name,status" {
+				t.Errorf("expected storagebox_status labels id,name,status, got %v", metric.Labels)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected storagebox_status in the schema, got %s", buf.String())
+	}
+	if got := calls.Load(); got != 0 {
+		t.Errorf("expected no API calls, got %d", got)
+	}
+}
+
+func TestPrintScrapeConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.Config
+		wantTarget  string
+		wantPath    string
+		wantScheme  string
+		errContains string
+	}{
+		{
+			name:       "default listen address",
+			cfg:        config.Config{ListenAddress: ":9509", MetricsPath: "/metrics"},
+			wantTarget: "'localhost:9509'",
+			wantPath:   "metrics_path: '/metrics'",
+			wantScheme: "scheme: http\n",
+		},
+		{
+			name:       "specific host, custom path and TLS",
+			cfg:        config.Config{ListenAddress: "10.0.0.5:9100", MetricsPath: "/custom-metrics", TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"},
+			wantTarget: "'10.0.0.5:9100'",
+			wantPath:   "metrics_path: '/custom-metrics'",
+			wantScheme: "scheme: https\n",
+		},
+		{
+			name:       "unspecified address",
+			cfg:        config.Config{ListenAddress: "0.0.0.0:9509", MetricsPath: "/metrics"},
+			wantTarget: "'localhost:9509'",
+			wantPath:   "metrics_path: '/metrics'",
+			wantScheme: "scheme: http\n",
+		},
+		{
+			name:        "invalid listen address",
+			cfg:         config.Config{ListenAddress: "9509", MetricsPath: "/metrics"},
+			errContains: "invalid listen address",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := printScrapeConfig(&buf, &tt.cfg)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("expected error containing %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("printScrapeConfig() unexpected error: %v", err)
+			}
+			got := buf.String()
+			for _, want := range []string{"scrape_configs:", tt.wantTarget, tt.wantPath, tt.wantScheme} {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected %q in the snippet, got:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMetricsSinceEndpoint(t *testing.T) {
+	c, _ := newTestCollector(t)
+
+	disabled, _ := newHandlers(&config.Config{MetricsPath: "/metrics"}, c)
+	server := httptest.NewServer(disabled)
+	defer server.Close()
+	if got := statusCode(t, http.MethodGet, server.URL+"/metrics/since?since=0"); got != http.StatusNotFound {
+		t.Errorf("expected /metrics/since to be disabled by default, got status %d", got)
+	}
+
+	enabled, _ := newHandlers(&config.Config{MetricsPath: "/metrics", MetricsSince: true, MaxConcurrentScrapes: 1}, c)
+	server = httptest.NewServer(enabled)
+	defer server.Close()
+	if got := statusCode(t, http.MethodGet, server.URL+"/metrics/since?since=0"); got != http.StatusOK {
+		t.Errorf("expected status 200 from /metrics/since, got %d", got)
+	}
+}
+
+func TestScrapeLimiterShared(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+	})
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	limit := newScrapeLimiter(1, 0)
+	slowServer := httptest.NewServer(limit(slow))
+	defer slowServer.Close()
+	fastServer := httptest.NewServer(limit(fast))
+	defer fastServer.Close()
+	// Closing the servers waits for the slow request
+	defer close(release)
+
+	go func() { _ = statusCode(t, http.MethodGet, slowServer.URL) }()
+	<-entered
+	if got := statusCode(t, http.MethodGet, fastServer.URL); got != http.StatusTooManyRequests {
+		t.Errorf("expected handlers of one limiter to share its slot, got status %d", got)
+	}
+}
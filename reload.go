@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/collector"
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/config"
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/hetzner"
+)
+
+// reloader re-reads the reloadable parts of the configuration at runtime,
+// triggered by SIGHUP. It keeps its own copy of the configuration, as the HTTP
+// handlers read the startup configuration concurrently; reloaded settings
+// reach the running exporter only through the log level variable and the
+// synchronized setters of the client and collector.
+type reloader struct {
+	cfg       *config.Config
+	client    *hetzner.Client
+	collector *collector.StorageBoxCollector
+	logLevel  *slog.LevelVar
+}
+
+// newReloader creates a reloader starting from a copy of cfg. Reloads must not
+// run concurrently.
+func newReloader(cfg *config.Config, client *hetzner.Client, c *collector.StorageBoxCollector, logLevel *slog.LevelVar) *reloader {
+	current := *cfg
+	return &reloader{cfg: &current, client: client, collector: c, logLevel: logLevel}
+}
+
+// reload re-reads the token file and the config file, applies the settings
+// that can change live and records the outcome in the audit log. A token file
+// that fails to read keeps the previous token without holding back the rest
+// of the reload; a config file that fails to read keeps the previous
+// configuration.
+func (r *reloader) reload(trigger string) {
+	var changes []string
+
+	// A bad rotation must not take down a running exporter: keep the
+	// previous token, count the failure and carry on with the config file
+	token, ok, tokenErr := r.cfg.ReloadToken()
+	if tokenErr != nil {
+		r.collector.RecordTokenReloadFailure()
+		slog.Warn("Token file reload failed, keeping the previous token", "error", tokenErr)
+	} else if ok && token != r.client.Token() {
+		changes = append(changes, fmt.Sprintf("hetzner_token: %s -> %s",
+			config.TokenFingerprint(r.client.Token()), config.TokenFingerprint(token)))
+		r.client.SetToken(token)
+		r.cfg.HetznerToken = token
+	}
+
+	next, ignored, err := r.cfg.Reload()
+	if err != nil {
+		auditReload(trigger, changes, errors.Join(tokenErr, err))
+		return
+	}
+	for _, key := range ignored {
+		slog.Warn("Setting changed in config file but requires a restart, ignored", "setting", key)
+	}
+
+	if next.LogLevel != r.cfg.LogLevel {
+		changes = append(changes, fmt.Sprintf("log_level: %s -> %s", r.cfg.LogLevel, next.LogLevel))
+		r.logLevel.Set(parseLogLevel(next.LogLevel))
+	}
+	if next.CacheTTL != r.cfg.CacheTTL {
+		changes = append(changes, fmt.Sprintf("cache_ttl: %s -> %s", r.cfg.CacheTTL, next.CacheTTL))
+		r.collector.SetCacheTTL(next.CacheTTL)
+	}
+	if next.SkipInactive != r.cfg.SkipInactive {
+		changes = append(changes, fmt.Sprintf("skip_inactive: %t -> %t", r.cfg.SkipInactive, next.SkipInactive))
+		r.collector.SetSkipInactive(next.SkipInactive)
+	}
+	r.cfg = next
+
+	auditReload(trigger, changes, tokenErr)
+}
+
+// auditReload emits a structured audit log entry for a configuration reload.
+// changes must never contain secrets; tokens are identified by fingerprint.
+func auditReload(trigger string, changes []string, err error) {
+	outcome := "success"
+	level := slog.LevelInfo
+	switch {
+	case err != nil:
+		outcome = "failure"
+		level = slog.LevelError
+	case len(changes) == 0:
+		outcome = "unchanged"
+	}
+
+	attrs := []any{
+		"audit", true,
+		"event", "config_reload",
+		"trigger", trigger,
+		"outcome", outcome,
+		"changes", changes,
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	slog.Log(context.Background(), level, "Configuration reload", attrs...)
+}
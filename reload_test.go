@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/config"
+	"github.com/crstian19/prometheus-storagebox-exporter/internal/hetzner"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/pflag"
+)
+
+// captureLogs redirects the default logger to a JSON buffer for the duration
+// of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// auditEntries returns the config reload audit entries found in logs
+func auditEntries(t *testing.T, logs *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if entry["event"] == "config_reload" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func TestReloadAuditLog(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("old-secret-token"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	cfg := &config.Config{HetznerToken: "old-secret-token", HetznerTokenFile: tokenFile}
+	client := hetzner.NewClient(cfg.HetznerToken)
+	r := newReloader(cfg, client, nil, nil)
+
+	if err := os.WriteFile(tokenFile, []byte("new-secret-token"), 0600); err != nil {
+		t.Fatalf("failed to rotate token file: %v", err)
+	}
+	logs := captureLogs(t)
+	r.reload("SIGHUP")
+
+	if client.Token() != "new-secret-token" {
+		t.Errorf("expected the rotated token to be in use, got %q", client.Token())
+	}
+
+	entries := auditEntries(t, logs)
+	if len(entries) != 1 {
+		t.Fatalf("expected one audit entry, got %d: %s", len(entries), logs)
+	}
+	entry := entries[0]
+	if entry["outcome"] != "success" || entry["trigger"] != "SIGHUP" || entry["audit"] != true {
+		t.Errorf("unexpected audit entry %v", entry)
+	}
+	changes, _ := entry["changes"].([]interface{})
+	if len(changes) != 1 || !strings.HasPrefix(changes[0].(string), "hetzner_token: ") {
+		t.Errorf("expected a hetzner_token change summary, got %v", entry["changes"])
+	}
+	if strings.Contains(logs.String(), "secret-token") {
+		t.Errorf("audit log leaks the token: %s", logs)
+	}
+}
+
+func TestReloadAuditLogFailure(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte(""), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	cfg := &config.Config{HetznerToken: "old-secret-token", HetznerTokenFile: tokenFile}
+	client := hetzner.NewClient(cfg.HetznerToken)
+	c, _ := newTestCollector(t)
+	logs := captureLogs(t)
+	newReloader(cfg, client, c, nil).reload("SIGHUP")
+
+	if client.Token() != "old-secret-token" {
+		t.Errorf("expected the previous token to be kept, got %q", client.Token())
+	}
+	entries := auditEntries(t, logs)
+	if len(entries) != 1 || entries[0]["outcome"] != "failure" || entries[0]["error"] == nil {
+		t.Errorf("expected a failure audit entry with an error, got %v", entries)
+	}
+}
+
+func TestReloadEmptiedTokenFile(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("old-secret-token"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+
+	cfg := &config.Config{HetznerToken: "old-secret-token", HetznerTokenFile: tokenFile}
+	client := hetzner.NewClient(cfg.HetznerToken)
+	c, _ := newTestCollector(t)
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	r := newReloader(cfg, client, c, nil)
+	captureLogs(t)
+
+	// A bad rotation empties the file, twice
+	if err := os.WriteFile(tokenFile, []byte("  \n"), 0600); err != nil {
+		t.Fatalf("failed to empty token file: %v", err)
+	}
+	r.reload("SIGHUP")
+	r.reload("SIGHUP")
+
+	if client.Token() != "old-secret-token" {
+		t.Errorf("expected the previous token to be kept, got %q", client.Token())
+	}
+	if r.cfg.HetznerToken != "old-secret-token" {
+		t.Errorf("expected the config to keep the previous token, got %q", cfg.HetznerToken)
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("failed to gather metrics: %v", err)
+	}
+	failures := -1.0
+	for _, mf := range families {
+		if mf.GetName() == "storagebox_exporter_token_reload_failures_total" {
+			failures = mf.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	if failures != 2 {
+		t.Errorf("expected 2 token reload failures, got %v", failures)
+	}
+}
+
+func TestReloadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exporter.conf")
+	if err := os.WriteFile(path, []byte("HETZNER_TOKEN=test-token\nLOG_LEVEL=info\n"), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	pflag.CommandLine = pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+	os.Args = []string{"test", "--config-file=" + path}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() unexpected error = %v", err)
+	}
+
+	c, calls := newTestCollector(t)
+	logLevel := new(slog.LevelVar)
+	r := newReloader(cfg, hetzner.NewClient(cfg.HetznerToken), c, logLevel)
+
+	if err := os.WriteFile(path, []byte("HETZNER_TOKEN=test-token\nLOG_LEVEL=debug\nCACHE_TTL=300\n"), 0600); err != nil {
+		t.Fatalf("failed to update config file: %v", err)
+	}
+	logs := captureLogs(t)
+	r.reload("SIGHUP")
+
+	if logLevel.Level() != slog.LevelDebug {
+		t.Errorf("expected log level debug after reload, got %v", logLevel.Level())
+	}
+	if r.cfg.LogLevel != "debug" || r.cfg.CacheTTL != 300*time.Second {
+		t.Errorf("expected reloaded config, got log level %s and cache TTL %s", r.cfg.LogLevel, r.cfg.CacheTTL)
+	}
+	// The configuration shared with the HTTP handlers is never written
+	if cfg.LogLevel != "info" || cfg.CacheTTL != 0 {
+		t.Errorf("expected the startup config to be untouched, got log level %s and cache TTL %s", cfg.LogLevel, cfg.CacheTTL)
+	}
+
+	// The cache is now enabled: two scrapes share one API call
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	for i := 0; i < 2; i++ {
+		if _, err := reg.Gather(); err != nil {
+			t.Fatalf("failed to gather metrics: %v", err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected the reloaded cache TTL to apply, got %d API calls", got)
+	}
+
+	entries := auditEntries(t, logs)
+	if len(entries) != 1 || entries[0]["outcome"] != "success" {
+		t.Fatalf("expected one successful audit entry, got %v", entries)
+	}
+	if changes, _ := entries[0]["changes"].([]interface{}); len(changes) != 2 {
+		t.Errorf("expected log level and cache TTL changes, got %v", entries[0]["changes"])
+	}
+}
+
+func TestReloadConfigFileAfterTokenFailure(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("old-secret-token"), 0600); err != nil {
+		t.Fatalf("failed to write token file: %v", err)
+	}
+	path := filepath.Join(dir, "exporter.conf")
+	if err := os.WriteFile(path, []byte("HETZNER_TOKEN_FILE="+tokenFile+"\nLOG_LEVEL=info\n"), 0600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	pflag.CommandLine = pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+	os.Args = []string{"test", "--config-file=" + path}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() unexpected error = %v", err)
+	}
+
+	client := hetzner.NewClient(cfg.HetznerToken)
+	c, _ := newTestCollector(t)
+	logLevel := new(slog.LevelVar)
+	r := newReloader(cfg, client, c, logLevel)
+
+	// A bad rotation empties the token file while the log level changes
+	if err := os.WriteFile(tokenFile, []byte(""), 0600); err != nil {
+		t.Fatalf("failed to empty token file: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("HETZNER_TOKEN_FILE="+tokenFile+"\nLOG_LEVEL=debug\n"), 0600); err != nil {
+		t.Fatalf("failed to update config file: %v", err)
+	}
+	logs := captureLogs(t)
+	r.reload("SIGHUP")
+
+	if client.Token() != "old-secret-token" || r.cfg.HetznerToken != "old-secret-token" {
+		t.Errorf("expected the previous token to be kept, got %q", client.Token())
+	}
+	if logLevel.Level() != slog.LevelDebug || r.cfg.LogLevel != "debug" {
+		t.Errorf("expected the config file change to apply despite the token failure, got log level %v", logLevel.Level())
+	}
+	entries := auditEntries(t, logs)
+	if len(entries) != 1 || entries[0]["outcome"] != "failure" || entries[0]["error"] == nil {
+		t.Fatalf("expected a failure audit entry with an error, got %v", entries)
+	}
+	if changes, _ := entries[0]["changes"].([]interface{}); len(changes) != 1 {
+		t.Errorf("expected the log level change, got %v", entries[0]["changes"])
+	}
+}